@@ -0,0 +1,706 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+// Code tables of the legacy charsets, for the letters which are not plain
+// ASCII.
+
+// TCVN3 (TCVN 5712:1993 VN3, the .VnTime fonts) has no uppercase letters with
+// a tone: they are written with the lowercase codes and an uppercase font
+// (.VnTimeH), so they share the lowercase codes here.
+var tcvn3Table = map[rune]byte{
+	'À': 0xb5,
+	'Á': 0xb8,
+	'Â': 0xa2,
+	'Ã': 0xb7,
+	'È': 0xcc,
+	'É': 0xd0,
+	'Ê': 0xa3,
+	'Ì': 0xd7,
+	'Í': 0xdd,
+	'Ò': 0xdf,
+	'Ó': 0xe3,
+	'Ô': 0xa4,
+	'Õ': 0xe2,
+	'Ù': 0xef,
+	'Ú': 0xf3,
+	'Ý': 0xfd,
+	'à': 0xb5,
+	'á': 0xb8,
+	'â': 0xa9,
+	'ã': 0xb7,
+	'è': 0xcc,
+	'é': 0xd0,
+	'ê': 0xaa,
+	'ì': 0xd7,
+	'í': 0xdd,
+	'ò': 0xdf,
+	'ó': 0xe3,
+	'ô': 0xab,
+	'õ': 0xe2,
+	'ù': 0xef,
+	'ú': 0xf3,
+	'ý': 0xfd,
+	'Ă': 0xa1,
+	'ă': 0xa8,
+	'Đ': 0xa7,
+	'đ': 0xae,
+	'Ĩ': 0xdc,
+	'ĩ': 0xdc,
+	'Ũ': 0xf2,
+	'ũ': 0xf2,
+	'Ơ': 0xa5,
+	'ơ': 0xac,
+	'Ư': 0xa6,
+	'ư': 0xad,
+	'Ạ': 0xb9,
+	'ạ': 0xb9,
+	'Ả': 0xb6,
+	'ả': 0xb6,
+	'Ấ': 0xca,
+	'ấ': 0xca,
+	'Ầ': 0xc7,
+	'ầ': 0xc7,
+	'Ẩ': 0xc8,
+	'ẩ': 0xc8,
+	'Ẫ': 0xc9,
+	'ẫ': 0xc9,
+	'Ậ': 0xcb,
+	'ậ': 0xcb,
+	'Ắ': 0xbe,
+	'ắ': 0xbe,
+	'Ằ': 0xbb,
+	'ằ': 0xbb,
+	'Ẳ': 0xbc,
+	'ẳ': 0xbc,
+	'Ẵ': 0xbd,
+	'ẵ': 0xbd,
+	'Ặ': 0xc6,
+	'ặ': 0xc6,
+	'Ẹ': 0xd1,
+	'ẹ': 0xd1,
+	'Ẻ': 0xce,
+	'ẻ': 0xce,
+	'Ẽ': 0xcf,
+	'ẽ': 0xcf,
+	'Ế': 0xd5,
+	'ế': 0xd5,
+	'Ề': 0xd2,
+	'ề': 0xd2,
+	'Ể': 0xd3,
+	'ể': 0xd3,
+	'Ễ': 0xd4,
+	'ễ': 0xd4,
+	'Ệ': 0xd6,
+	'ệ': 0xd6,
+	'Ỉ': 0xd8,
+	'ỉ': 0xd8,
+	'Ị': 0xde,
+	'ị': 0xde,
+	'Ọ': 0xe4,
+	'ọ': 0xe4,
+	'Ỏ': 0xe1,
+	'ỏ': 0xe1,
+	'Ố': 0xe8,
+	'ố': 0xe8,
+	'Ồ': 0xe5,
+	'ồ': 0xe5,
+	'Ổ': 0xe6,
+	'ổ': 0xe6,
+	'Ỗ': 0xe7,
+	'ỗ': 0xe7,
+	'Ộ': 0xe9,
+	'ộ': 0xe9,
+	'Ớ': 0xed,
+	'ớ': 0xed,
+	'Ờ': 0xea,
+	'ờ': 0xea,
+	'Ở': 0xeb,
+	'ở': 0xeb,
+	'Ỡ': 0xec,
+	'ỡ': 0xec,
+	'Ợ': 0xee,
+	'ợ': 0xee,
+	'Ụ': 0xf4,
+	'ụ': 0xf4,
+	'Ủ': 0xf1,
+	'ủ': 0xf1,
+	'Ứ': 0xf8,
+	'ứ': 0xf8,
+	'Ừ': 0xf5,
+	'ừ': 0xf5,
+	'Ử': 0xf6,
+	'ử': 0xf6,
+	'Ữ': 0xf7,
+	'ữ': 0xf7,
+	'Ự': 0xf9,
+	'ự': 0xf9,
+	'Ỳ': 0xfa,
+	'ỳ': 0xfa,
+	'Ỵ': 0xfe,
+	'ỵ': 0xfe,
+	'Ỷ': 0xfb,
+	'ỷ': 0xfb,
+	'Ỹ': 0xfc,
+	'ỹ': 0xfc,
+}
+
+// VISCII (RFC 1456) has a code for every Vietnamese letter, some of them in
+// the C0 control range.
+var visciiTable = map[rune]byte{
+	'À': 0xc0,
+	'Á': 0xc1,
+	'Â': 0xc2,
+	'Ã': 0xc3,
+	'È': 0xc8,
+	'É': 0xc9,
+	'Ê': 0xca,
+	'Ì': 0xcc,
+	'Í': 0xcd,
+	'Ò': 0xd2,
+	'Ó': 0xd3,
+	'Ô': 0xd4,
+	'Õ': 0xa0,
+	'Ù': 0xd9,
+	'Ú': 0xda,
+	'Ý': 0xdd,
+	'à': 0xe0,
+	'á': 0xe1,
+	'â': 0xe2,
+	'ã': 0xe3,
+	'è': 0xe8,
+	'é': 0xe9,
+	'ê': 0xea,
+	'ì': 0xec,
+	'í': 0xed,
+	'ò': 0xf2,
+	'ó': 0xf3,
+	'ô': 0xf4,
+	'õ': 0xf5,
+	'ù': 0xf9,
+	'ú': 0xfa,
+	'ý': 0xfd,
+	'Ă': 0xc5,
+	'ă': 0xe5,
+	'Đ': 0xd0,
+	'đ': 0xf0,
+	'Ĩ': 0xce,
+	'ĩ': 0xee,
+	'Ũ': 0x9d,
+	'ũ': 0xfb,
+	'Ơ': 0xb4,
+	'ơ': 0xbd,
+	'Ư': 0xbf,
+	'ư': 0xdf,
+	'Ạ': 0x80,
+	'ạ': 0xd5,
+	'Ả': 0xc4,
+	'ả': 0xe4,
+	'Ấ': 0x84,
+	'ấ': 0xa4,
+	'Ầ': 0x85,
+	'ầ': 0xa5,
+	'Ẩ': 0x86,
+	'ẩ': 0xa6,
+	'Ẫ': 0x06,
+	'ẫ': 0xe7,
+	'Ậ': 0x87,
+	'ậ': 0xa7,
+	'Ắ': 0x81,
+	'ắ': 0xa1,
+	'Ằ': 0x82,
+	'ằ': 0xa2,
+	'Ẳ': 0x02,
+	'ẳ': 0xc6,
+	'Ẵ': 0x05,
+	'ẵ': 0xc7,
+	'Ặ': 0x83,
+	'ặ': 0xa3,
+	'Ẹ': 0x89,
+	'ẹ': 0xa9,
+	'Ẻ': 0xcb,
+	'ẻ': 0xeb,
+	'Ẽ': 0x88,
+	'ẽ': 0xa8,
+	'Ế': 0x8a,
+	'ế': 0xaa,
+	'Ề': 0x8b,
+	'ề': 0xab,
+	'Ể': 0x8c,
+	'ể': 0xac,
+	'Ễ': 0x8d,
+	'ễ': 0xad,
+	'Ệ': 0x8e,
+	'ệ': 0xae,
+	'Ỉ': 0x9b,
+	'ỉ': 0xef,
+	'Ị': 0x98,
+	'ị': 0xb8,
+	'Ọ': 0x9a,
+	'ọ': 0xf7,
+	'Ỏ': 0x99,
+	'ỏ': 0xf6,
+	'Ố': 0x8f,
+	'ố': 0xaf,
+	'Ồ': 0x90,
+	'ồ': 0xb0,
+	'Ổ': 0x91,
+	'ổ': 0xb1,
+	'Ỗ': 0x92,
+	'ỗ': 0xb2,
+	'Ộ': 0x93,
+	'ộ': 0xb5,
+	'Ớ': 0x95,
+	'ớ': 0xbe,
+	'Ờ': 0x96,
+	'ờ': 0xb6,
+	'Ở': 0x97,
+	'ở': 0xb7,
+	'Ỡ': 0xb3,
+	'ỡ': 0xde,
+	'Ợ': 0x94,
+	'ợ': 0xfe,
+	'Ụ': 0x9e,
+	'ụ': 0xf8,
+	'Ủ': 0x9c,
+	'ủ': 0xfc,
+	'Ứ': 0xba,
+	'ứ': 0xd1,
+	'Ừ': 0xbb,
+	'ừ': 0xd7,
+	'Ử': 0xbc,
+	'ử': 0xd8,
+	'Ữ': 0xff,
+	'ữ': 0xe6,
+	'Ự': 0xb9,
+	'ự': 0xf1,
+	'Ỳ': 0x9f,
+	'ỳ': 0xcf,
+	'Ỵ': 0x1e,
+	'ỵ': 0xdc,
+	'Ỷ': 0x14,
+	'ỷ': 0xd6,
+	'Ỹ': 0x19,
+	'ỹ': 0xdb,
+}
+
+// VNI-Windows writes most letters as an ASCII base letter followed by a
+// diacritic byte, the uppercase letters using the uppercase diacritics.
+var vniWindowsTable = map[rune]string{
+	'À': "AØ",
+	'Á': "AÙ",
+	'Â': "AÂ",
+	'Ã': "AÕ",
+	'È': "EØ",
+	'É': "EÙ",
+	'Ê': "EÂ",
+	'Ì': "Ì",
+	'Í': "Í",
+	'Ò': "OØ",
+	'Ó': "OÙ",
+	'Ô': "OÂ",
+	'Õ': "OÕ",
+	'Ù': "UØ",
+	'Ú': "UÙ",
+	'Ý': "YÙ",
+	'à': "aø",
+	'á': "aù",
+	'â': "aâ",
+	'ã': "aõ",
+	'è': "eø",
+	'é': "eù",
+	'ê': "eâ",
+	'ì': "ì",
+	'í': "í",
+	'ò': "oø",
+	'ó': "où",
+	'ô': "oâ",
+	'õ': "oõ",
+	'ù': "uø",
+	'ú': "uù",
+	'ý': "yù",
+	'Ă': "AÊ",
+	'ă': "aê",
+	'Đ': "Ñ",
+	'đ': "ñ",
+	'Ĩ': "Ó",
+	'ĩ': "ó",
+	'Ũ': "UÕ",
+	'ũ': "uõ",
+	'Ơ': "Ô",
+	'ơ': "ô",
+	'Ư': "Ö",
+	'ư': "ö",
+	'Ạ': "AÏ",
+	'ạ': "aï",
+	'Ả': "AÛ",
+	'ả': "aû",
+	'Ấ': "AÁ",
+	'ấ': "aá",
+	'Ầ': "AÀ",
+	'ầ': "aà",
+	'Ẩ': "AÅ",
+	'ẩ': "aå",
+	'Ẫ': "AÃ",
+	'ẫ': "aã",
+	'Ậ': "AÄ",
+	'ậ': "aä",
+	'Ắ': "AÉ",
+	'ắ': "aé",
+	'Ằ': "AÈ",
+	'ằ': "aè",
+	'Ẳ': "AÚ",
+	'ẳ': "aú",
+	'Ẵ': "AÜ",
+	'ẵ': "aü",
+	'Ặ': "AË",
+	'ặ': "aë",
+	'Ẹ': "EÏ",
+	'ẹ': "eï",
+	'Ẻ': "EÛ",
+	'ẻ': "eû",
+	'Ẽ': "EÕ",
+	'ẽ': "eõ",
+	'Ế': "EÁ",
+	'ế': "eá",
+	'Ề': "EÀ",
+	'ề': "eà",
+	'Ể': "EÅ",
+	'ể': "eå",
+	'Ễ': "EÃ",
+	'ễ': "eã",
+	'Ệ': "EÄ",
+	'ệ': "eä",
+	'Ỉ': "Æ",
+	'ỉ': "æ",
+	'Ị': "Ò",
+	'ị': "ò",
+	'Ọ': "OÏ",
+	'ọ': "oï",
+	'Ỏ': "OÛ",
+	'ỏ': "oû",
+	'Ố': "OÁ",
+	'ố': "oá",
+	'Ồ': "OÀ",
+	'ồ': "oà",
+	'Ổ': "OÅ",
+	'ổ': "oå",
+	'Ỗ': "OÃ",
+	'ỗ': "oã",
+	'Ộ': "OÄ",
+	'ộ': "oä",
+	'Ớ': "ÔÙ",
+	'ớ': "ôù",
+	'Ờ': "ÔØ",
+	'ờ': "ôø",
+	'Ở': "ÔÛ",
+	'ở': "ôû",
+	'Ỡ': "ÔÕ",
+	'ỡ': "ôõ",
+	'Ợ': "ÔÏ",
+	'ợ': "ôï",
+	'Ụ': "UÏ",
+	'ụ': "uï",
+	'Ủ': "UÛ",
+	'ủ': "uû",
+	'Ứ': "ÖÙ",
+	'ứ': "öù",
+	'Ừ': "ÖØ",
+	'ừ': "öø",
+	'Ử': "ÖÛ",
+	'ử': "öû",
+	'Ữ': "ÖÕ",
+	'ữ': "öõ",
+	'Ự': "ÖÏ",
+	'ự': "öï",
+	'Ỳ': "YØ",
+	'ỳ': "yø",
+	'Ỵ': "Î",
+	'ỵ': "î",
+	'Ỷ': "YÛ",
+	'ỷ': "yû",
+	'Ỹ': "YÕ",
+	'ỹ': "yõ",
+}
+
+// Unicode tổ hợp is the canonical decomposition (NFD) of the letters: a base
+// letter followed by its combining marks.
+var unicodeNFDTable = map[rune]string{
+	'À': "A\u0300",
+	'Á': "A\u0301",
+	'Â': "A\u0302",
+	'Ã': "A\u0303",
+	'È': "E\u0300",
+	'É': "E\u0301",
+	'Ê': "E\u0302",
+	'Ì': "I\u0300",
+	'Í': "I\u0301",
+	'Ò': "O\u0300",
+	'Ó': "O\u0301",
+	'Ô': "O\u0302",
+	'Õ': "O\u0303",
+	'Ù': "U\u0300",
+	'Ú': "U\u0301",
+	'Ý': "Y\u0301",
+	'à': "a\u0300",
+	'á': "a\u0301",
+	'â': "a\u0302",
+	'ã': "a\u0303",
+	'è': "e\u0300",
+	'é': "e\u0301",
+	'ê': "e\u0302",
+	'ì': "i\u0300",
+	'í': "i\u0301",
+	'ò': "o\u0300",
+	'ó': "o\u0301",
+	'ô': "o\u0302",
+	'õ': "o\u0303",
+	'ù': "u\u0300",
+	'ú': "u\u0301",
+	'ý': "y\u0301",
+	'Ă': "A\u0306",
+	'ă': "a\u0306",
+	'Ĩ': "I\u0303",
+	'ĩ': "i\u0303",
+	'Ũ': "U\u0303",
+	'ũ': "u\u0303",
+	'Ơ': "O\u031b",
+	'ơ': "o\u031b",
+	'Ư': "U\u031b",
+	'ư': "u\u031b",
+	'Ạ': "A\u0323",
+	'ạ': "a\u0323",
+	'Ả': "A\u0309",
+	'ả': "a\u0309",
+	'Ấ': "A\u0302\u0301",
+	'ấ': "a\u0302\u0301",
+	'Ầ': "A\u0302\u0300",
+	'ầ': "a\u0302\u0300",
+	'Ẩ': "A\u0302\u0309",
+	'ẩ': "a\u0302\u0309",
+	'Ẫ': "A\u0302\u0303",
+	'ẫ': "a\u0302\u0303",
+	'Ậ': "A\u0323\u0302",
+	'ậ': "a\u0323\u0302",
+	'Ắ': "A\u0306\u0301",
+	'ắ': "a\u0306\u0301",
+	'Ằ': "A\u0306\u0300",
+	'ằ': "a\u0306\u0300",
+	'Ẳ': "A\u0306\u0309",
+	'ẳ': "a\u0306\u0309",
+	'Ẵ': "A\u0306\u0303",
+	'ẵ': "a\u0306\u0303",
+	'Ặ': "A\u0323\u0306",
+	'ặ': "a\u0323\u0306",
+	'Ẹ': "E\u0323",
+	'ẹ': "e\u0323",
+	'Ẻ': "E\u0309",
+	'ẻ': "e\u0309",
+	'Ẽ': "E\u0303",
+	'ẽ': "e\u0303",
+	'Ế': "E\u0302\u0301",
+	'ế': "e\u0302\u0301",
+	'Ề': "E\u0302\u0300",
+	'ề': "e\u0302\u0300",
+	'Ể': "E\u0302\u0309",
+	'ể': "e\u0302\u0309",
+	'Ễ': "E\u0302\u0303",
+	'ễ': "e\u0302\u0303",
+	'Ệ': "E\u0323\u0302",
+	'ệ': "e\u0323\u0302",
+	'Ỉ': "I\u0309",
+	'ỉ': "i\u0309",
+	'Ị': "I\u0323",
+	'ị': "i\u0323",
+	'Ọ': "O\u0323",
+	'ọ': "o\u0323",
+	'Ỏ': "O\u0309",
+	'ỏ': "o\u0309",
+	'Ố': "O\u0302\u0301",
+	'ố': "o\u0302\u0301",
+	'Ồ': "O\u0302\u0300",
+	'ồ': "o\u0302\u0300",
+	'Ổ': "O\u0302\u0309",
+	'ổ': "o\u0302\u0309",
+	'Ỗ': "O\u0302\u0303",
+	'ỗ': "o\u0302\u0303",
+	'Ộ': "O\u0323\u0302",
+	'ộ': "o\u0323\u0302",
+	'Ớ': "O\u031b\u0301",
+	'ớ': "o\u031b\u0301",
+	'Ờ': "O\u031b\u0300",
+	'ờ': "o\u031b\u0300",
+	'Ở': "O\u031b\u0309",
+	'ở': "o\u031b\u0309",
+	'Ỡ': "O\u031b\u0303",
+	'ỡ': "o\u031b\u0303",
+	'Ợ': "O\u031b\u0323",
+	'ợ': "o\u031b\u0323",
+	'Ụ': "U\u0323",
+	'ụ': "u\u0323",
+	'Ủ': "U\u0309",
+	'ủ': "u\u0309",
+	'Ứ': "U\u031b\u0301",
+	'ứ': "u\u031b\u0301",
+	'Ừ': "U\u031b\u0300",
+	'ừ': "u\u031b\u0300",
+	'Ử': "U\u031b\u0309",
+	'ử': "u\u031b\u0309",
+	'Ữ': "U\u031b\u0303",
+	'ữ': "u\u031b\u0303",
+	'Ự': "U\u031b\u0323",
+	'ự': "u\u031b\u0323",
+	'Ỳ': "Y\u0300",
+	'ỳ': "y\u0300",
+	'Ỵ': "Y\u0323",
+	'ỵ': "y\u0323",
+	'Ỷ': "Y\u0309",
+	'ỷ': "y\u0309",
+	'Ỹ': "Y\u0303",
+	'ỹ': "y\u0303",
+}
+
+// CP1258 (Windows-1258) has codes for some letters with a tone, the others
+// are written as a letter without tone followed by a combining tone byte.
+var cp1258Table = map[rune]string{
+	'À': "À",
+	'Á': "Á",
+	'Â': "Â",
+	'Ã': "AÞ",
+	'È': "È",
+	'É': "É",
+	'Ê': "Ê",
+	'Ì': "IÌ",
+	'Í': "Í",
+	'Ò': "OÌ",
+	'Ó': "Ó",
+	'Ô': "Ô",
+	'Õ': "OÞ",
+	'Ù': "Ù",
+	'Ú': "Ú",
+	'Ý': "Yì",
+	'à': "à",
+	'á': "á",
+	'â': "â",
+	'ã': "aÞ",
+	'è': "è",
+	'é': "é",
+	'ê': "ê",
+	'ì': "iÌ",
+	'í': "í",
+	'ò': "oÌ",
+	'ó': "ó",
+	'ô': "ô",
+	'õ': "oÞ",
+	'ù': "ù",
+	'ú': "ú",
+	'ý': "yì",
+	'Ă': "Ã",
+	'ă': "ã",
+	'Đ': "Ð",
+	'đ': "ð",
+	'Ĩ': "IÞ",
+	'ĩ': "iÞ",
+	'Ũ': "UÞ",
+	'ũ': "uÞ",
+	'Ơ': "Õ",
+	'ơ': "õ",
+	'Ư': "Ý",
+	'ư': "ý",
+	'Ạ': "Aò",
+	'ạ': "aò",
+	'Ả': "AÒ",
+	'ả': "aÒ",
+	'Ấ': "Âì",
+	'ấ': "âì",
+	'Ầ': "ÂÌ",
+	'ầ': "âÌ",
+	'Ẩ': "ÂÒ",
+	'ẩ': "âÒ",
+	'Ẫ': "ÂÞ",
+	'ẫ': "âÞ",
+	'Ậ': "Âò",
+	'ậ': "âò",
+	'Ắ': "Ãì",
+	'ắ': "ãì",
+	'Ằ': "ÃÌ",
+	'ằ': "ãÌ",
+	'Ẳ': "ÃÒ",
+	'ẳ': "ãÒ",
+	'Ẵ': "ÃÞ",
+	'ẵ': "ãÞ",
+	'Ặ': "Ãò",
+	'ặ': "ãò",
+	'Ẹ': "Eò",
+	'ẹ': "eò",
+	'Ẻ': "EÒ",
+	'ẻ': "eÒ",
+	'Ẽ': "EÞ",
+	'ẽ': "eÞ",
+	'Ế': "Êì",
+	'ế': "êì",
+	'Ề': "ÊÌ",
+	'ề': "êÌ",
+	'Ể': "ÊÒ",
+	'ể': "êÒ",
+	'Ễ': "ÊÞ",
+	'ễ': "êÞ",
+	'Ệ': "Êò",
+	'ệ': "êò",
+	'Ỉ': "IÒ",
+	'ỉ': "iÒ",
+	'Ị': "Iò",
+	'ị': "iò",
+	'Ọ': "Oò",
+	'ọ': "oò",
+	'Ỏ': "OÒ",
+	'ỏ': "oÒ",
+	'Ố': "Ôì",
+	'ố': "ôì",
+	'Ồ': "ÔÌ",
+	'ồ': "ôÌ",
+	'Ổ': "ÔÒ",
+	'ổ': "ôÒ",
+	'Ỗ': "ÔÞ",
+	'ỗ': "ôÞ",
+	'Ộ': "Ôò",
+	'ộ': "ôò",
+	'Ớ': "Õì",
+	'ớ': "õì",
+	'Ờ': "ÕÌ",
+	'ờ': "õÌ",
+	'Ở': "ÕÒ",
+	'ở': "õÒ",
+	'Ỡ': "ÕÞ",
+	'ỡ': "õÞ",
+	'Ợ': "Õò",
+	'ợ': "õò",
+	'Ụ': "Uò",
+	'ụ': "uò",
+	'Ủ': "UÒ",
+	'ủ': "uÒ",
+	'Ứ': "Ýì",
+	'ứ': "ýì",
+	'Ừ': "ÝÌ",
+	'ừ': "ýÌ",
+	'Ử': "ÝÒ",
+	'ử': "ýÒ",
+	'Ữ': "ÝÞ",
+	'ữ': "ýÞ",
+	'Ự': "Ýò",
+	'ự': "ýò",
+	'Ỳ': "YÌ",
+	'ỳ': "yÌ",
+	'Ỵ': "Yò",
+	'ỵ': "yò",
+	'Ỷ': "YÒ",
+	'ỷ': "yÒ",
+	'Ỹ': "YÞ",
+	'ỹ': "yÞ",
+}
@@ -0,0 +1,192 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A CharsetGuess is a charset some text may be encoded in. Confidence is the
+// share of the words with non-ASCII bytes that decode to valid syllables.
+type CharsetGuess struct {
+	Charset    string
+	Confidence float64
+}
+
+// detectableCharsets are the charsets DetectCharset picks from, the most
+// likely first when the scores are even. The 7-bit charsets are left out as
+// any ASCII text would decode with them.
+var detectableCharsets = []string{UNICODE, UnicodeNFD, TCVN3, VISCII, VNIWindows, CP1258}
+
+// DetectCharset guesses the encoding of Vietnamese text, the best guess first.
+// Text without any non-ASCII byte gets no confidence in any charset.
+func DetectCharset(data []byte) []CharsetGuess {
+	var words = splitEncodedWords(data)
+	return detectCharset(words, words)
+}
+
+// DetectTextCharset is DetectCharset for text that was already read as UTF-8,
+// such as the clipboard, where legacy charsets show up as one rune per byte.
+func DetectTextCharset(text string) []CharsetGuess {
+	var legacyWords [][]byte
+	if data, ok := runesToBytes(text); ok {
+		legacyWords = splitEncodedWords(data)
+	}
+	return detectCharset(legacyWords, splitEncodedWords([]byte(text)))
+}
+
+// detectCharset scores the words as seen by the legacy charsets and by the
+// Unicode ones, the same words in both lists.
+func detectCharset(legacyWords, unicodeWords [][]byte) []CharsetGuess {
+	var guesses []CharsetGuess
+	for _, name := range detectableCharsets {
+		var nValid = 0
+		var words = unicodeWords
+		if IsLegacyCharset(name) {
+			words = legacyWords
+		}
+		for _, word := range words {
+			var text string
+			if IsLegacyCharset(name) {
+				text = bytesToRunes(word)
+			} else if utf8.Valid(word) {
+				text = string(word)
+			} else {
+				continue
+			}
+			if isValidWord(Decode(name, text)) {
+				nValid++
+			}
+		}
+		var guess = CharsetGuess{Charset: name}
+		if len(unicodeWords) > 0 {
+			guess.Confidence = float64(nValid) / float64(len(unicodeWords))
+		}
+		guesses = append(guesses, guess)
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		return guesses[i].Confidence > guesses[j].Confidence
+	})
+	return guesses
+}
+
+// splitEncodedWords returns the words of data that hold a non-ASCII byte or a
+// control code, which is where the charsets differ. Words are separated by
+// ASCII spaces, digits and punctuation, which no charset uses for letters.
+func splitEncodedWords(data []byte) [][]byte {
+	var words [][]byte
+	var start = 0
+	var isEncoded = false
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && !isWordSeparator(data[i]) {
+			isEncoded = isEncoded || data[i] >= utf8.RuneSelf || data[i] < ' '
+			continue
+		}
+		if isEncoded {
+			words = append(words, data[start:i])
+		}
+		start = i + 1
+		isEncoded = false
+	}
+	return words
+}
+
+func isWordSeparator(b byte) bool {
+	if b >= utf8.RuneSelf {
+		return false
+	}
+	var chr = rune(b)
+	return unicode.IsSpace(chr) || unicode.IsDigit(chr) || unicode.IsPunct(chr) || unicode.IsSymbol(chr)
+}
+
+func bytesToRunes(data []byte) string {
+	var runes = make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func runesToBytes(text string) ([]byte, bool) {
+	var data []byte
+	for _, chr := range text {
+		if chr > 0xFF {
+			return nil, false
+		}
+		data = append(data, byte(chr))
+	}
+	return data, true
+}
+
+// isValidWord reports whether word is a single Vietnamese syllable with at
+// most one tone, which must suit its last consonant.
+func isValidWord(word string) bool {
+	var tone = ToneNone
+	var letters []rune
+	for _, chr := range strings.ToLower(word) {
+		var toneless = AddToneToChar(chr, 0)
+		if !IsAlpha(AddMarkToTonelessChar(toneless, 0)) {
+			return false
+		}
+		if t := FindToneFromChar(chr); t != ToneNone {
+			if tone != ToneNone {
+				return false
+			}
+			tone = t
+		}
+		letters = append(letters, toneless)
+	}
+	var fc, vo, lc, ok = splitSyllable(letters)
+	if !ok {
+		return false
+	}
+	if tone != ToneNone && tone != ToneAcute && tone != ToneDot && inStringList([]string{"c", "k", "p", "t", "ch"}, lc) {
+		return false
+	}
+	return isValidCVC(fc, vo, lc, true)
+}
+
+// splitSyllable separates the toneless letters of a syllable into its first
+// consonant, vowel and last consonant, "gi" and "qu" being consonants.
+func splitSyllable(letters []rune) (string, string, string, bool) {
+	var i = 0
+	for i < len(letters) && !IsVowel(letters[i]) {
+		i++
+	}
+	var j = i
+	for j < len(letters) && IsVowel(letters[j]) {
+		j++
+	}
+	var fc, vo, lc = letters[:i], letters[i:j], letters[j:]
+	if len(vo) == 0 {
+		return "", "", "", false
+	}
+	for _, chr := range lc {
+		if IsVowel(chr) {
+			return "", "", "", false
+		}
+	}
+	if string(fc) == "g" && len(vo) > 1 && vo[0] == 'i' && !(AddMarkToTonelessChar(vo[1], 0) == 'e' && len(lc) > 0) ||
+		string(fc) == "q" && vo[0] == 'u' {
+		fc, vo = letters[:i+1], letters[i+1:j]
+	}
+	return string(fc), string(vo), string(lc), len(vo) > 0
+}
+
+func inStringList(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"testing"
+)
+
+const detectorSample = "Tiếng Việt là ngôn ngữ của người Việt và là ngôn ngữ chính thức tại Việt Nam. " +
+	"Quốc ngữ được viết bằng chữ Latinh, có thêm dấu để ghi thanh điệu, giữa những năm 1900."
+
+func TestDetectCharset(t *testing.T) {
+	for _, name := range detectableCharsets {
+		var encoded = Encode(name, detectorSample)
+		var data = []byte(encoded)
+		if IsLegacyCharset(name) {
+			data = nil
+			for _, chr := range encoded {
+				data = append(data, byte(chr))
+			}
+		}
+		var guesses = DetectCharset(data)
+		if len(guesses) != len(detectableCharsets) {
+			t.Fatalf("DetectCharset %s. Got %d guesses, expected %d", name, len(guesses), len(detectableCharsets))
+		}
+		if guesses[0].Charset != name || guesses[0].Confidence != 1 {
+			t.Errorf("DetectCharset %s. Got %v", name, guesses)
+		}
+		// NFC text is also valid NFD text without combining marks
+		if guesses[1].Confidence == 1 && !(name == UNICODE && guesses[1].Charset == UnicodeNFD) {
+			t.Errorf("DetectCharset %s. Got a second guess of %v", name, guesses[1])
+		}
+	}
+}
+
+func TestDetectTextCharset(t *testing.T) {
+	for _, name := range detectableCharsets {
+		var guesses = DetectTextCharset(Encode(name, detectorSample))
+		if guesses[0].Charset != name || guesses[0].Confidence != 1 {
+			t.Errorf("DetectTextCharset %s. Got %v", name, guesses)
+		}
+	}
+	// only Latin-1 letters, which a legacy charset would read as other letters
+	if guesses := DetectTextCharset("cà phê"); guesses[0].Charset != UNICODE {
+		t.Errorf("DetectTextCharset Unicode. Got %v", guesses)
+	}
+}
+
+func TestDetectCharsetASCII(t *testing.T) {
+	for _, guess := range DetectCharset([]byte("Tieng Viet, 100%")) {
+		if guess.Confidence != 0 {
+			t.Errorf("DetectCharset ASCII. Got %v", guess)
+		}
+	}
+}
+
+func TestIsValidWord(t *testing.T) {
+	var tests = []struct {
+		word     string
+		expected bool
+	}{
+		{"người", true},
+		{"Giữa", true},
+		{"gìn", true},
+		{"quốc", true},
+		{"nghiêng", true},
+		{"khuya", true},
+		{"tiếng", true},
+		{"tiềc", false},
+		{"Viêät", false},
+		{"ươc", true},
+		{"ñoù", false},
+		{"chứa", true},
+		{"ñuôi", false},
+	}
+	for _, test := range tests {
+		if got := isValidWord(test.word); got != test.expected {
+			t.Errorf("isValidWord %s. Got %v, expected %v", test.word, got, test.expected)
+		}
+	}
+}
@@ -8,12 +8,308 @@
 
 package core
 
-const UNICODE = "Unicode"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
 
+const (
+	UNICODE    = "Unicode"
+	UnicodeNFD = "Unicode tổ hợp"
+	TCVN3      = "TCVN3 (ABC)"
+	VISCII     = "VISCII"
+	VNIWindows = "VNI Windows"
+	CP1258     = "CP1258"
+	VIQR       = "VIQR"
+
+	NCRDecimal    = "NCR Decimal"
+	NCRHex        = "NCR Hex"
+	UnicodeEscape = "Unicode Escape"
+	URLEncoding   = "URL Encoding"
+)
+
+// A Charset converts Unicode text to an output encoding and back. A legacy
+// encoding yields one rune per byte (U+0000 to U+00FF), which is what the
+// applications using legacy Vietnamese fonts get when typing those bytes.
+type Charset struct {
+	Name   string
+	Encode func(string) string
+	Decode func(string) string
+}
+
+// VPS and BK HCM2 are out of scope: no reference code table was available to
+// check an encoder against.
+var charsets = []Charset{
+	{UNICODE, identity, identity},
+	newMultiByteCharset(UnicodeNFD, unicodeNFDTable),
+	newSingleByteCharset(TCVN3, tcvn3Table),
+	newSingleByteCharset(VISCII, visciiTable),
+	newMultiByteCharset(VNIWindows, vniWindowsTable),
+	newMultiByteCharset(CP1258, cp1258Table),
+	{VIQR, encodeVIQR, decodeVIQR},
+	newEscapeCharset(NCRDecimal, escapeNCRDecimal, regNCRDecimal, unescapeNCR),
+	newEscapeCharset(NCRHex, escapeNCRHex, regNCRHex, unescapeNCR),
+	newEscapeCharset(UnicodeEscape, escapeUnicode, regUnicodeEscape, unescapeUnicode),
+	newEscapeCharset(URLEncoding, escapeURL, regURLEncoding, unescapeURL),
+}
+
+// legacyCharsets are the byte encodings, whose files hold one byte per rune of
+// the encoded text rather than UTF-8.
+var legacyCharsets = []string{TCVN3, VISCII, VNIWindows, CP1258}
+
+// combiningCharsets write tones and marks as Unicode combining characters,
+// which applications delete together with their base letter.
+var combiningCharsets = []string{UnicodeNFD}
+
+func identity(input string) string {
+	return input
+}
+
+// Encode converts input to the named charset. Characters the charset can not
+// represent, and input for an unknown charset, are left as they are.
 func Encode(charsetName string, input string) string {
+	if charset, found := findCharset(charsetName); found {
+		return charset.Encode(input)
+	}
+	return input
+}
+
+// Decode converts input from the named charset back to Unicode.
+func Decode(charsetName string, input string) string {
+	if charset, found := findCharset(charsetName); found {
+		return charset.Decode(input)
+	}
 	return input
 }
 
 func GetCharsetNames() []string {
-	return []string{UNICODE}
+	var names []string
+	for _, charset := range charsets {
+		names = append(names, charset.Name)
+	}
+	return names
+}
+
+// IsLegacyCharset reports whether the named charset is a byte encoding, whose
+// encoded runes are the bytes to write (see Charset).
+func IsLegacyCharset(charsetName string) bool {
+	for _, name := range legacyCharsets {
+		if name == charsetName {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitGraphemes splits text encoded in the named charset into the characters
+// an application deletes with one backspace: a base character with the
+// combining marks after it for the combining charsets, a single rune otherwise.
+func SplitGraphemes(charsetName string, text string) []string {
+	var isCombining = false
+	for _, name := range combiningCharsets {
+		if name == charsetName {
+			isCombining = true
+		}
+	}
+	var graphemes []string
+	for _, chr := range text {
+		if isCombining && len(graphemes) > 0 && unicode.Is(unicode.Mn, chr) {
+			graphemes[len(graphemes)-1] += string(chr)
+			continue
+		}
+		graphemes = append(graphemes, string(chr))
+	}
+	return graphemes
+}
+
+func findCharset(charsetName string) (Charset, bool) {
+	for _, charset := range charsets {
+		if charset.Name == charsetName {
+			return charset, true
+		}
+	}
+	return Charset{}, false
+}
+
+func newSingleByteCharset(name string, table map[rune]byte) Charset {
+	var reverse = make(map[rune]rune)
+	for chr, b := range table {
+		// letters sharing a code decode to the lowercase one
+		if other, found := reverse[rune(b)]; !found || unicode.IsUpper(other) {
+			reverse[rune(b)] = chr
+		}
+	}
+	return Charset{
+		Name: name,
+		Encode: func(input string) string {
+			return strings.Map(func(chr rune) rune {
+				if b, found := table[chr]; found {
+					return rune(b)
+				}
+				return chr
+			}, input)
+		},
+		Decode: func(input string) string {
+			return strings.Map(func(chr rune) rune {
+				if decoded, found := reverse[chr]; found {
+					return decoded
+				}
+				return chr
+			}, input)
+		},
+	}
+}
+
+// newMultiByteCharset makes a charset where a letter may take several bytes.
+// Decoding takes the longest sequence of the table at each position.
+func newMultiByteCharset(name string, table map[rune]string) Charset {
+	var reverse = make(map[string]rune)
+	var maxLen = 1
+	for chr, seq := range table {
+		reverse[seq] = chr
+		if n := len([]rune(seq)); n > maxLen {
+			maxLen = n
+		}
+	}
+	return Charset{
+		Name: name,
+		Encode: func(input string) string {
+			var b strings.Builder
+			for _, chr := range input {
+				if seq, found := table[chr]; found {
+					b.WriteString(seq)
+				} else {
+					b.WriteRune(chr)
+				}
+			}
+			return b.String()
+		},
+		Decode: func(input string) string {
+			var b strings.Builder
+			var runes = []rune(input)
+			for i := 0; i < len(runes); {
+				var n = maxLen
+				if i+n > len(runes) {
+					n = len(runes) - i
+				}
+				for ; n > 0; n-- {
+					if chr, found := reverse[string(runes[i:i+n])]; found {
+						b.WriteRune(chr)
+						break
+					}
+				}
+				if n == 0 {
+					b.WriteRune(runes[i])
+					n = 1
+				}
+				i += n
+			}
+			return b.String()
+		},
+	}
+}
+
+// newEscapeCharset makes a charset for source code, markup or URLs, where the
+// characters outside of ASCII are written as escape sequences. Decoding
+// replaces the matches of pattern that unescape accepts.
+func newEscapeCharset(name string, escape func(rune) string, pattern *regexp.Regexp, unescape func(string) (string, bool)) Charset {
+	return Charset{
+		Name: name,
+		Encode: func(input string) string {
+			var b strings.Builder
+			for _, chr := range input {
+				if chr < utf8.RuneSelf {
+					b.WriteRune(chr)
+				} else {
+					b.WriteString(escape(chr))
+				}
+			}
+			return b.String()
+		},
+		Decode: func(input string) string {
+			return pattern.ReplaceAllStringFunc(input, func(match string) string {
+				if unescaped, ok := unescape(match); ok {
+					return unescaped
+				}
+				return match
+			})
+		},
+	}
+}
+
+var (
+	regNCRDecimal    = regexp.MustCompile(`&#[0-9]+;`)
+	regNCRHex        = regexp.MustCompile(`&#[xX][0-9a-fA-F]+;`)
+	regUnicodeEscape = regexp.MustCompile(`(\\u[0-9a-fA-F]{4})+`)
+	regURLEncoding   = regexp.MustCompile(`(%[0-9a-fA-F]{2})+`)
+)
+
+func escapeNCRDecimal(chr rune) string {
+	return fmt.Sprintf("&#%d;", chr)
+}
+
+func escapeNCRHex(chr rune) string {
+	return fmt.Sprintf("&#x%X;", chr)
+}
+
+func unescapeNCR(match string) (string, bool) {
+	var number = strings.TrimSuffix(strings.TrimPrefix(match, "&#"), ";")
+	var base = 10
+	if number[0] == 'x' || number[0] == 'X' {
+		number, base = number[1:], 16
+	}
+	var code, err = strconv.ParseUint(number, base, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return "", false
+	}
+	return string(rune(code)), true
+}
+
+// escapeUnicode writes the \uXXXX escapes of Java, JavaScript or JSON, with a
+// surrogate pair outside of the BMP.
+func escapeUnicode(chr rune) string {
+	if r1, r2 := utf16.EncodeRune(chr); r1 != unicode.ReplacementChar {
+		return fmt.Sprintf("\\u%04X\\u%04X", r1, r2)
+	}
+	return fmt.Sprintf("\\u%04X", chr)
+}
+
+func unescapeUnicode(match string) (string, bool) {
+	var units []uint16
+	for _, hex := range strings.Split(match, `\u`)[1:] {
+		var unit, _ = strconv.ParseUint(hex, 16, 16)
+		units = append(units, uint16(unit))
+	}
+	return string(utf16.Decode(units)), true
+}
+
+// escapeURL percent-encodes the UTF-8 bytes of chr.
+func escapeURL(chr rune) string {
+	var b strings.Builder
+	for _, c := range []byte(string(chr)) {
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// unescapeURL only decodes a run of percent-encoded bytes which is valid UTF-8
+// outside of ASCII, since the encoder leaves ASCII as is.
+func unescapeURL(match string) (string, bool) {
+	var bytes []byte
+	for _, hex := range strings.Split(match, "%")[1:] {
+		var c, _ = strconv.ParseUint(hex, 16, 8)
+		if c < utf8.RuneSelf {
+			return "", false
+		}
+		bytes = append(bytes, byte(c))
+	}
+	if !utf8.Valid(bytes) {
+		return "", false
+	}
+	return string(bytes), true
 }
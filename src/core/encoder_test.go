@@ -0,0 +1,322 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// The codes of every Vietnamese letter which is not plain ASCII.
+var legacyCharsetTests = []struct {
+	letter     rune
+	tcvn3      byte
+	viscii     byte
+	vniWindows string
+	unicodeNFD string
+	cp1258     string
+}{
+	{'À', 0xb5, 0xc0, "AØ", "A\u0300", "À"},
+	{'Á', 0xb8, 0xc1, "AÙ", "A\u0301", "Á"},
+	{'Â', 0xa2, 0xc2, "AÂ", "A\u0302", "Â"},
+	{'Ã', 0xb7, 0xc3, "AÕ", "A\u0303", "AÞ"},
+	{'È', 0xcc, 0xc8, "EØ", "E\u0300", "È"},
+	{'É', 0xd0, 0xc9, "EÙ", "E\u0301", "É"},
+	{'Ê', 0xa3, 0xca, "EÂ", "E\u0302", "Ê"},
+	{'Ì', 0xd7, 0xcc, "Ì", "I\u0300", "IÌ"},
+	{'Í', 0xdd, 0xcd, "Í", "I\u0301", "Í"},
+	{'Ò', 0xdf, 0xd2, "OØ", "O\u0300", "OÌ"},
+	{'Ó', 0xe3, 0xd3, "OÙ", "O\u0301", "Ó"},
+	{'Ô', 0xa4, 0xd4, "OÂ", "O\u0302", "Ô"},
+	{'Õ', 0xe2, 0xa0, "OÕ", "O\u0303", "OÞ"},
+	{'Ù', 0xef, 0xd9, "UØ", "U\u0300", "Ù"},
+	{'Ú', 0xf3, 0xda, "UÙ", "U\u0301", "Ú"},
+	{'Ý', 0xfd, 0xdd, "YÙ", "Y\u0301", "Yì"},
+	{'à', 0xb5, 0xe0, "aø", "a\u0300", "à"},
+	{'á', 0xb8, 0xe1, "aù", "a\u0301", "á"},
+	{'â', 0xa9, 0xe2, "aâ", "a\u0302", "â"},
+	{'ã', 0xb7, 0xe3, "aõ", "a\u0303", "aÞ"},
+	{'è', 0xcc, 0xe8, "eø", "e\u0300", "è"},
+	{'é', 0xd0, 0xe9, "eù", "e\u0301", "é"},
+	{'ê', 0xaa, 0xea, "eâ", "e\u0302", "ê"},
+	{'ì', 0xd7, 0xec, "ì", "i\u0300", "iÌ"},
+	{'í', 0xdd, 0xed, "í", "i\u0301", "í"},
+	{'ò', 0xdf, 0xf2, "oø", "o\u0300", "oÌ"},
+	{'ó', 0xe3, 0xf3, "où", "o\u0301", "ó"},
+	{'ô', 0xab, 0xf4, "oâ", "o\u0302", "ô"},
+	{'õ', 0xe2, 0xf5, "oõ", "o\u0303", "oÞ"},
+	{'ù', 0xef, 0xf9, "uø", "u\u0300", "ù"},
+	{'ú', 0xf3, 0xfa, "uù", "u\u0301", "ú"},
+	{'ý', 0xfd, 0xfd, "yù", "y\u0301", "yì"},
+	{'Ă', 0xa1, 0xc5, "AÊ", "A\u0306", "Ã"},
+	{'ă', 0xa8, 0xe5, "aê", "a\u0306", "ã"},
+	{'Đ', 0xa7, 0xd0, "Ñ", "Đ", "Ð"},
+	{'đ', 0xae, 0xf0, "ñ", "đ", "ð"},
+	{'Ĩ', 0xdc, 0xce, "Ó", "I\u0303", "IÞ"},
+	{'ĩ', 0xdc, 0xee, "ó", "i\u0303", "iÞ"},
+	{'Ũ', 0xf2, 0x9d, "UÕ", "U\u0303", "UÞ"},
+	{'ũ', 0xf2, 0xfb, "uõ", "u\u0303", "uÞ"},
+	{'Ơ', 0xa5, 0xb4, "Ô", "O\u031b", "Õ"},
+	{'ơ', 0xac, 0xbd, "ô", "o\u031b", "õ"},
+	{'Ư', 0xa6, 0xbf, "Ö", "U\u031b", "Ý"},
+	{'ư', 0xad, 0xdf, "ö", "u\u031b", "ý"},
+	{'Ạ', 0xb9, 0x80, "AÏ", "A\u0323", "Aò"},
+	{'ạ', 0xb9, 0xd5, "aï", "a\u0323", "aò"},
+	{'Ả', 0xb6, 0xc4, "AÛ", "A\u0309", "AÒ"},
+	{'ả', 0xb6, 0xe4, "aû", "a\u0309", "aÒ"},
+	{'Ấ', 0xca, 0x84, "AÁ", "A\u0302\u0301", "Âì"},
+	{'ấ', 0xca, 0xa4, "aá", "a\u0302\u0301", "âì"},
+	{'Ầ', 0xc7, 0x85, "AÀ", "A\u0302\u0300", "ÂÌ"},
+	{'ầ', 0xc7, 0xa5, "aà", "a\u0302\u0300", "âÌ"},
+	{'Ẩ', 0xc8, 0x86, "AÅ", "A\u0302\u0309", "ÂÒ"},
+	{'ẩ', 0xc8, 0xa6, "aå", "a\u0302\u0309", "âÒ"},
+	{'Ẫ', 0xc9, 0x06, "AÃ", "A\u0302\u0303", "ÂÞ"},
+	{'ẫ', 0xc9, 0xe7, "aã", "a\u0302\u0303", "âÞ"},
+	{'Ậ', 0xcb, 0x87, "AÄ", "A\u0323\u0302", "Âò"},
+	{'ậ', 0xcb, 0xa7, "aä", "a\u0323\u0302", "âò"},
+	{'Ắ', 0xbe, 0x81, "AÉ", "A\u0306\u0301", "Ãì"},
+	{'ắ', 0xbe, 0xa1, "aé", "a\u0306\u0301", "ãì"},
+	{'Ằ', 0xbb, 0x82, "AÈ", "A\u0306\u0300", "ÃÌ"},
+	{'ằ', 0xbb, 0xa2, "aè", "a\u0306\u0300", "ãÌ"},
+	{'Ẳ', 0xbc, 0x02, "AÚ", "A\u0306\u0309", "ÃÒ"},
+	{'ẳ', 0xbc, 0xc6, "aú", "a\u0306\u0309", "ãÒ"},
+	{'Ẵ', 0xbd, 0x05, "AÜ", "A\u0306\u0303", "ÃÞ"},
+	{'ẵ', 0xbd, 0xc7, "aü", "a\u0306\u0303", "ãÞ"},
+	{'Ặ', 0xc6, 0x83, "AË", "A\u0323\u0306", "Ãò"},
+	{'ặ', 0xc6, 0xa3, "aë", "a\u0323\u0306", "ãò"},
+	{'Ẹ', 0xd1, 0x89, "EÏ", "E\u0323", "Eò"},
+	{'ẹ', 0xd1, 0xa9, "eï", "e\u0323", "eò"},
+	{'Ẻ', 0xce, 0xcb, "EÛ", "E\u0309", "EÒ"},
+	{'ẻ', 0xce, 0xeb, "eû", "e\u0309", "eÒ"},
+	{'Ẽ', 0xcf, 0x88, "EÕ", "E\u0303", "EÞ"},
+	{'ẽ', 0xcf, 0xa8, "eõ", "e\u0303", "eÞ"},
+	{'Ế', 0xd5, 0x8a, "EÁ", "E\u0302\u0301", "Êì"},
+	{'ế', 0xd5, 0xaa, "eá", "e\u0302\u0301", "êì"},
+	{'Ề', 0xd2, 0x8b, "EÀ", "E\u0302\u0300", "ÊÌ"},
+	{'ề', 0xd2, 0xab, "eà", "e\u0302\u0300", "êÌ"},
+	{'Ể', 0xd3, 0x8c, "EÅ", "E\u0302\u0309", "ÊÒ"},
+	{'ể', 0xd3, 0xac, "eå", "e\u0302\u0309", "êÒ"},
+	{'Ễ', 0xd4, 0x8d, "EÃ", "E\u0302\u0303", "ÊÞ"},
+	{'ễ', 0xd4, 0xad, "eã", "e\u0302\u0303", "êÞ"},
+	{'Ệ', 0xd6, 0x8e, "EÄ", "E\u0323\u0302", "Êò"},
+	{'ệ', 0xd6, 0xae, "eä", "e\u0323\u0302", "êò"},
+	{'Ỉ', 0xd8, 0x9b, "Æ", "I\u0309", "IÒ"},
+	{'ỉ', 0xd8, 0xef, "æ", "i\u0309", "iÒ"},
+	{'Ị', 0xde, 0x98, "Ò", "I\u0323", "Iò"},
+	{'ị', 0xde, 0xb8, "ò", "i\u0323", "iò"},
+	{'Ọ', 0xe4, 0x9a, "OÏ", "O\u0323", "Oò"},
+	{'ọ', 0xe4, 0xf7, "oï", "o\u0323", "oò"},
+	{'Ỏ', 0xe1, 0x99, "OÛ", "O\u0309", "OÒ"},
+	{'ỏ', 0xe1, 0xf6, "oû", "o\u0309", "oÒ"},
+	{'Ố', 0xe8, 0x8f, "OÁ", "O\u0302\u0301", "Ôì"},
+	{'ố', 0xe8, 0xaf, "oá", "o\u0302\u0301", "ôì"},
+	{'Ồ', 0xe5, 0x90, "OÀ", "O\u0302\u0300", "ÔÌ"},
+	{'ồ', 0xe5, 0xb0, "oà", "o\u0302\u0300", "ôÌ"},
+	{'Ổ', 0xe6, 0x91, "OÅ", "O\u0302\u0309", "ÔÒ"},
+	{'ổ', 0xe6, 0xb1, "oå", "o\u0302\u0309", "ôÒ"},
+	{'Ỗ', 0xe7, 0x92, "OÃ", "O\u0302\u0303", "ÔÞ"},
+	{'ỗ', 0xe7, 0xb2, "oã", "o\u0302\u0303", "ôÞ"},
+	{'Ộ', 0xe9, 0x93, "OÄ", "O\u0323\u0302", "Ôò"},
+	{'ộ', 0xe9, 0xb5, "oä", "o\u0323\u0302", "ôò"},
+	{'Ớ', 0xed, 0x95, "ÔÙ", "O\u031b\u0301", "Õì"},
+	{'ớ', 0xed, 0xbe, "ôù", "o\u031b\u0301", "õì"},
+	{'Ờ', 0xea, 0x96, "ÔØ", "O\u031b\u0300", "ÕÌ"},
+	{'ờ', 0xea, 0xb6, "ôø", "o\u031b\u0300", "õÌ"},
+	{'Ở', 0xeb, 0x97, "ÔÛ", "O\u031b\u0309", "ÕÒ"},
+	{'ở', 0xeb, 0xb7, "ôû", "o\u031b\u0309", "õÒ"},
+	{'Ỡ', 0xec, 0xb3, "ÔÕ", "O\u031b\u0303", "ÕÞ"},
+	{'ỡ', 0xec, 0xde, "ôõ", "o\u031b\u0303", "õÞ"},
+	{'Ợ', 0xee, 0x94, "ÔÏ", "O\u031b\u0323", "Õò"},
+	{'ợ', 0xee, 0xfe, "ôï", "o\u031b\u0323", "õò"},
+	{'Ụ', 0xf4, 0x9e, "UÏ", "U\u0323", "Uò"},
+	{'ụ', 0xf4, 0xf8, "uï", "u\u0323", "uò"},
+	{'Ủ', 0xf1, 0x9c, "UÛ", "U\u0309", "UÒ"},
+	{'ủ', 0xf1, 0xfc, "uû", "u\u0309", "uÒ"},
+	{'Ứ', 0xf8, 0xba, "ÖÙ", "U\u031b\u0301", "Ýì"},
+	{'ứ', 0xf8, 0xd1, "öù", "u\u031b\u0301", "ýì"},
+	{'Ừ', 0xf5, 0xbb, "ÖØ", "U\u031b\u0300", "ÝÌ"},
+	{'ừ', 0xf5, 0xd7, "öø", "u\u031b\u0300", "ýÌ"},
+	{'Ử', 0xf6, 0xbc, "ÖÛ", "U\u031b\u0309", "ÝÒ"},
+	{'ử', 0xf6, 0xd8, "öû", "u\u031b\u0309", "ýÒ"},
+	{'Ữ', 0xf7, 0xff, "ÖÕ", "U\u031b\u0303", "ÝÞ"},
+	{'ữ', 0xf7, 0xe6, "öõ", "u\u031b\u0303", "ýÞ"},
+	{'Ự', 0xf9, 0xb9, "ÖÏ", "U\u031b\u0323", "Ýò"},
+	{'ự', 0xf9, 0xf1, "öï", "u\u031b\u0323", "ýò"},
+	{'Ỳ', 0xfa, 0x9f, "YØ", "Y\u0300", "YÌ"},
+	{'ỳ', 0xfa, 0xcf, "yø", "y\u0300", "yÌ"},
+	{'Ỵ', 0xfe, 0x1e, "Î", "Y\u0323", "Yò"},
+	{'ỵ', 0xfe, 0xdc, "î", "y\u0323", "yò"},
+	{'Ỷ', 0xfb, 0x14, "YÛ", "Y\u0309", "YÒ"},
+	{'ỷ', 0xfb, 0xd6, "yû", "y\u0309", "yÒ"},
+	{'Ỹ', 0xfc, 0x19, "YÕ", "Y\u0303", "YÞ"},
+	{'ỹ', 0xfc, 0xdb, "yõ", "y\u0303", "yÞ"},
+}
+
+func TestLegacyCharsets(t *testing.T) {
+	for _, test := range legacyCharsetTests {
+		for _, c := range []struct {
+			name     string
+			expected string
+		}{
+			{TCVN3, string(rune(test.tcvn3))},
+			{VISCII, string(rune(test.viscii))},
+			{VNIWindows, test.vniWindows},
+			{UnicodeNFD, test.unicodeNFD},
+			{CP1258, test.cp1258},
+		} {
+			var encoded = Encode(c.name, string(test.letter))
+			if encoded != c.expected {
+				t.Errorf("Encode %c to %s. Got %q, expected %q", test.letter, c.name, encoded, c.expected)
+				continue
+			}
+			var expected = test.letter
+			if c.name == TCVN3 && !inKeyList([]rune("ĂÂÊÔƠƯĐ"), test.letter) {
+				// TCVN3 has no codes for uppercase letters with a tone
+				expected = unicode.ToLower(test.letter)
+			}
+			if decoded := Decode(c.name, encoded); decoded != string(expected) {
+				t.Errorf("Decode %q from %s. Got %s, expected %c", encoded, c.name, decoded, expected)
+			}
+		}
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	var tests = []struct {
+		charset  string
+		input    string
+		expected string
+	}{
+		{UNICODE, "Tiếng Việt", "Tiếng Việt"},
+		{TCVN3, "Tiếng Việt", "Ti\u00d5ng Vi\u00d6t"},
+		{VISCII, "Tiếng Việt", "Ti\u00aang Vi\u00aet"},
+		{VNIWindows, "Tiếng Việt", "Tieáng Vieät"},
+		{VNIWindows, "Người Đó", "Ngöôøi Ñoù"},
+		{UnicodeNFD, "Tiếng Việt", "Tie\u0302\u0301ng Vie\u0323\u0302t"},
+		{CP1258, "Tiếng Việt", "Tiêìng Viêòt"},
+		{NCRDecimal, "mới <b>", "m&#7899;i <b>"},
+		{NCRHex, "mới <b>", "m&#x1EDB;i <b>"},
+		{UnicodeEscape, "mới \"x\"", `m\u1EDBi "x"`},
+		{UnicodeEscape, "🙂", `\uD83D\uDE42`},
+		{URLEncoding, "mới 100%", "m%E1%BB%9Bi 100%"},
+		{"Unknown", "Tiếng Việt", "Tiếng Việt"},
+	}
+	for _, test := range tests {
+		if got := Encode(test.charset, test.input); got != test.expected {
+			t.Errorf("Encode %s to %s. Got %q, expected %q", test.input, test.charset, got, test.expected)
+		}
+		if got := Decode(test.charset, test.expected); got != test.input {
+			t.Errorf("Decode %q from %s. Got %s, expected %s", test.expected, test.charset, got, test.input)
+		}
+	}
+}
+
+func TestDecodeEscapes(t *testing.T) {
+	var tests = []struct {
+		charset  string
+		input    string
+		expected string
+	}{
+		{NCRDecimal, "&#7899; &#x1EDB; &#99999999;", "ớ &#x1EDB; &#99999999;"},
+		{NCRHex, "&#x1edb; &#7899;", "ớ &#7899;"},
+		{UnicodeEscape, `\u1edb \u00`, `ớ \u00`},
+		{URLEncoding, "%E1%BB%9B %20 %E1%BB", "ớ %20 %E1%BB"},
+	}
+	for _, test := range tests {
+		if got := Decode(test.charset, test.input); got != test.expected {
+			t.Errorf("Decode %s from %s. Got %s, expected %s", test.input, test.charset, got, test.expected)
+		}
+	}
+}
+
+func TestVIQR(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"Việt Nam", "Vie^.t Nam"},
+		{"Đường đi", "DDu+o+`ng ddi"},
+		{"Người Hà Nội.", "Ngu+o+`i Ha` No^.i\\."},
+		{"Ăn chưa?", "A(n chu+a\\?"},
+		{"Sao (đẹp) không", "Sao (dde.p) kho^ng"},
+		{"a(b) e^", "a\\(b) e\\^"},
+		{"d.d, dd", "d.d, d\\d"},
+		{`C:\temp`, `C:\\temp`},
+		{"Quá 100% rồi!", "Qua' 100% ro^`i!"},
+	}
+	for _, test := range tests {
+		if got := Encode(VIQR, test.input); got != test.expected {
+			t.Errorf("Encode %s to VIQR. Got %q, expected %q", test.input, got, test.expected)
+		}
+		if got := Decode(VIQR, test.expected); got != test.input {
+			t.Errorf("Decode %q from VIQR. Got %s, expected %s", test.expected, got, test.input)
+		}
+	}
+	for _, test := range legacyCharsetTests {
+		var encoded = Encode(VIQR, string(test.letter))
+		if decoded := Decode(VIQR, encoded); decoded != string(test.letter) {
+			t.Errorf("Decode %q from VIQR. Got %s, expected %c", encoded, decoded, test.letter)
+		}
+	}
+}
+
+func TestCharsetRoundTrip(t *testing.T) {
+	var letters []rune
+	for _, test := range legacyCharsetTests {
+		if !unicode.IsUpper(test.letter) || inKeyList([]rune("ĂÂÊÔƠƯĐ"), test.letter) {
+			letters = append(letters, test.letter)
+		}
+	}
+	var text = string(letters) + " Người Việt ở đâu? Hà Nội. \\ 100% &"
+	for _, name := range GetCharsetNames() {
+		if decoded := Decode(name, Encode(name, text)); decoded != text {
+			t.Errorf("Decode from %s. Got %q, expected %q", name, decoded, text)
+		}
+	}
+}
+
+func TestSplitGraphemes(t *testing.T) {
+	var tests = []struct {
+		charset  string
+		input    string
+		expected int
+	}{
+		{UnicodeNFD, "tiếng Việt", 10},
+		{UnicodeNFD, "\u0301a", 2},
+		{UNICODE, "tiếng Việt", 10},
+		{CP1258, "tiếng Việt", 12},
+		{VNIWindows, "tiếng Việt", 12},
+	}
+	for _, test := range tests {
+		var graphemes = SplitGraphemes(test.charset, Encode(test.charset, test.input))
+		if len(graphemes) != test.expected {
+			t.Errorf("Split %s encoded in %s. Got %q, expected %d graphemes", test.input, test.charset, graphemes, test.expected)
+		}
+		if joined := strings.Join(graphemes, ""); joined != Encode(test.charset, test.input) {
+			t.Errorf("Join the graphemes of %s in %s. Got %q", test.input, test.charset, joined)
+		}
+	}
+}
+
+func TestRemoveAccents(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"Đường về Hà Nội", "Duong ve Ha Noi"},
+		{"NGƯỜI Việt", "NGUOI Viet"},
+		{"garçon café", "garçon cafe"},
+	}
+	for _, test := range tests {
+		if got := RemoveAccents(test.input); got != test.expected {
+			t.Errorf("RemoveAccents %s. Got %s, expected %s", test.input, got, test.expected)
+		}
+	}
+}
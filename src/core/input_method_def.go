@@ -8,6 +8,10 @@
 
 package core
 
+import (
+	"strings"
+)
+
 type InputMethodDefinition map[string]string
 
 var InputMethodDefinitions = map[string]InputMethodDefinition{
@@ -24,6 +28,102 @@ var InputMethodDefinitions = map[string]InputMethodDefinition{
 		"w": "UOA_ƯƠĂ",
 		"d": "D_Đ",
 	},
+	"Simple Telex": {
+		"z": "XoaDauThanh",
+		"s": "DauSac",
+		"f": "DauHuyen",
+		"r": "DauHoi",
+		"x": "DauNga",
+		"j": "DauNang",
+		"a": "A_Â",
+		"e": "E_Ê",
+		"o": "O_Ô",
+		"w": "UOA_ƯƠĂ",
+		"d": "D_Đ",
+	},
+	"Telex 2": {
+		"z": "XoaDauThanh",
+		"s": "DauSac",
+		"f": "DauHuyen",
+		"r": "DauHoi",
+		"x": "DauNga",
+		"j": "DauNang",
+		"a": "A_Â",
+		"e": "E_Ê",
+		"o": "O_Ô",
+		"w": "UOA_ƯƠĂ__Ư",
+		"d": "D_Đ",
+		"]": "__ư",
+		"[": "__ơ",
+		"}": "__Ư",
+		"{": "__Ơ",
+	},
+	"VNI": {
+		"0": "XoaDauThanh",
+		"1": "DauSac",
+		"2": "DauHuyen",
+		"3": "DauHoi",
+		"4": "DauNga",
+		"5": "DauNang",
+		"6": "AEO_ÂÊÔ",
+		"7": "UO_ƯƠ",
+		"8": "A_Ă",
+		"9": "D_Đ",
+	},
+	"VIQR": {
+		"'": "DauSac",
+		"`": "DauHuyen",
+		"?": "DauHoi",
+		"~": "DauNga",
+		".": "DauNang",
+		"^": "AEO_ÂÊÔ",
+		"(": "A_Ă",
+		"+": "UO_ƯƠ",
+		"d": "D_Đ",
+	},
+}
+
+// CombinedInputMethodSeparator joins the names of the definitions that make up
+// a combined input method, e.g. "Telex + VNI".
+const CombinedInputMethodSeparator = "+"
+
+func init() {
+	InputMethodDefinitions["Telex + VNI"] = MergeInputMethodDefinitions(InputMethodDefinitions["Telex"], InputMethodDefinitions["VNI"])
+}
+
+// MergeInputMethodDefinitions merges the given definitions into a new one. If a
+// key is bound by more than one definition, the first binding wins.
+func MergeInputMethodDefinitions(imDefs ...InputMethodDefinition) InputMethodDefinition {
+	var merged = make(InputMethodDefinition)
+	for _, imDef := range imDefs {
+		for key, line := range imDef {
+			if _, found := merged[key]; !found {
+				merged[key] = line
+			}
+		}
+	}
+	return merged
+}
+
+// findInputMethodDefinition looks up imName, falling back to merging the
+// definitions named by its parts when it is a combination like "Telex + VNI".
+func findInputMethodDefinition(imDef map[string]InputMethodDefinition, imName string) (InputMethodDefinition, bool) {
+	if definition, found := imDef[imName]; found {
+		return definition, true
+	}
+	var names = strings.Split(imName, CombinedInputMethodSeparator)
+	if len(names) < 2 {
+		return nil, false
+	}
+	var definitions []InputMethodDefinition
+	for _, name := range names {
+		var definition, found = imDef[strings.TrimSpace(name)]
+		if !found {
+			return nil, false
+		}
+		definitions = append(definitions, definition)
+	}
+	return MergeInputMethodDefinitions(definitions...), true
 }
 
 func GetInputMethodDefinitions() map[string]InputMethodDefinition {
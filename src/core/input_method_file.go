@@ -0,0 +1,257 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// InputMethodFileExt is the extension of input method definition files.
+const InputMethodFileExt = ".im"
+
+/*
+An InputMethodFile is an input method read from a definition file. Each line
+of such a file is either empty, a comment, a directive or a key binding:
+
+	# Telex with a few shortcuts
+	@name        Telex Plus
+	@author      Nguyễn Văn A
+	@description Telex where [ and ] type ơ and ư
+	@include     Telex       # a built-in input method
+	@include     extra.im    # another file, relative to this one
+	[            __ơ
+	]            __ư
+	uow          ươ;priority=1
+	\#           DauNga      # keys starting with # or @ are escaped
+
+A binding is a key (or a key sequence) followed by a rule in the usual
+definition syntax. Bindings override the ones of included definitions, but a
+key can only be bound once per file. The name defaults to the file name.
+*/
+type InputMethodFile struct {
+	Path        string
+	Name        string
+	Author      string
+	Description string
+	Definition  InputMethodDefinition
+	Keys        []string // in the order they are bound
+	locations   map[string]fileLocation
+}
+
+type fileLocation struct {
+	path string
+	line int
+}
+
+var regTrailingComment = regexp.MustCompile(`\s+#.*$`)
+
+// LoadInputMethodFile reads the definition file at path. A file that can not be
+// read yields an error and no InputMethodFile, while problems in its content
+// yield a ParseErrors next to whatever could be loaded.
+func LoadInputMethodFile(path string) (*InputMethodFile, error) {
+	var f = &InputMethodFile{
+		Path:       path,
+		Name:       strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Definition: InputMethodDefinition{},
+		locations:  map[string]fileLocation{},
+	}
+	var errs, err = f.load(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return f, errs.withInputMethod(f.Name)
+	}
+	return f, nil
+}
+
+// LoadInputMethodDirs loads every definition file of the given directories,
+// sorted by file name. A method defined in a later directory replaces the one
+// with the same name in an earlier directory, so user files can override
+// system ones. Missing directories are skipped.
+func LoadInputMethodDirs(dirs ...string) ([]*InputMethodFile, error) {
+	var files []*InputMethodFile
+	var errs ParseErrors
+	for _, dir := range dirs {
+		var paths, _ = filepath.Glob(filepath.Join(dir, "*"+InputMethodFileExt))
+		sort.Strings(paths)
+		var loaded = map[string]string{}
+		for _, path := range paths {
+			var f, err = LoadInputMethodFile(path)
+			if parseErrs, ok := err.(ParseErrors); ok {
+				errs = append(errs, parseErrs...)
+			} else if err != nil {
+				errs = append(errs, &ParseError{Source: path, Message: err.Error()})
+			}
+			if f == nil {
+				continue
+			}
+			if other, found := loaded[f.Name]; found {
+				errs = append(errs, &ParseError{InputMethod: f.Name, Source: path, Message: fmt.Sprintf("already defined in %s", other)})
+				continue
+			}
+			loaded[f.Name] = path
+			files = replaceInputMethodFile(files, f)
+		}
+	}
+	if len(errs) > 0 {
+		return files, errs
+	}
+	return files, nil
+}
+
+func replaceInputMethodFile(files []*InputMethodFile, f *InputMethodFile) []*InputMethodFile {
+	for i, other := range files {
+		if other.Name == f.Name {
+			files[i] = f
+			return files
+		}
+	}
+	return append(files, f)
+}
+
+// Parse parses the input method defined by the file. Parse errors point to the
+// file and line where the faulty key is bound.
+func (f *InputMethodFile) Parse() (InputMethod, error) {
+	var im, errs = parseInputMethod(f.Name, f.Definition)
+	if len(errs) == 0 {
+		return im, nil
+	}
+	for _, err := range errs {
+		if location, found := f.locations[err.Key]; found {
+			err.Source, err.LineNumber = location.path, location.line
+		}
+	}
+	return im, errs
+}
+
+// load reads the bindings and directives of path into f, includes being loaded
+// recursively. The bindings of path are applied after all of its includes,
+// wherever the @include lines are. The only error it returns is a failure to
+// read path itself.
+func (f *InputMethodFile) load(path string, including []string) (ParseErrors, error) {
+	var file, err = os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var errs ParseErrors
+	var bound = map[string]int{}
+	var bindings [][2]string
+	var scanner = bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = regTrailingComment.ReplaceAllString(line, "")
+		var newError = func(key, message string) *ParseError {
+			return &ParseError{Key: key, Line: line, Message: message, Source: path, LineNumber: lineNumber}
+		}
+		if strings.HasPrefix(line, "@") {
+			var directive = strings.Fields(line)[0]
+			var value = strings.TrimSpace(strings.TrimPrefix(line, directive))
+			if value == "" {
+				errs = append(errs, newError("", fmt.Sprintf("%s needs a value", directive)))
+				continue
+			}
+			switch directive {
+			case "@name":
+				if len(including) == 0 {
+					f.Name = value
+				}
+			case "@author":
+				if len(including) == 0 {
+					f.Author = value
+				}
+			case "@description":
+				if len(including) == 0 {
+					f.Description = value
+				}
+			case "@include":
+				errs = append(errs, f.include(path, value, lineNumber, append(including, path))...)
+			default:
+				errs = append(errs, newError("", fmt.Sprintf("unknown directive %s", directive)))
+			}
+			continue
+		}
+		var fields = strings.Fields(line)
+		if len(fields) != 2 {
+			errs = append(errs, newError("", "a binding must be a key followed by a rule"))
+			continue
+		}
+		var key = strings.TrimPrefix(fields[0], `\`)
+		if key == "" {
+			errs = append(errs, newError("", "a key can not be empty"))
+			continue
+		}
+		if previous, found := bound[key]; found {
+			errs = append(errs, newError(key, fmt.Sprintf("already bound at line %d", previous)))
+			continue
+		}
+		bound[key] = lineNumber
+		bindings = append(bindings, [2]string{key, fields[1]})
+	}
+	if err = scanner.Err(); err != nil {
+		errs = append(errs, &ParseError{Source: path, Message: err.Error()})
+	}
+	for _, binding := range bindings {
+		f.bind(binding[0], binding[1], fileLocation{path, bound[binding[0]]})
+	}
+	return errs, nil
+}
+
+// include loads another definition file, relative to path, or a built-in input
+// method when value is not a file name.
+func (f *InputMethodFile) include(path, value string, lineNumber int, including []string) ParseErrors {
+	var location = fileLocation{path, lineNumber}
+	if !strings.HasSuffix(value, InputMethodFileExt) {
+		var definition, found = findInputMethodDefinition(InputMethodDefinitions, value)
+		if !found {
+			return ParseErrors{{Source: path, LineNumber: lineNumber, Message: fmt.Sprintf("unknown input method %q", value)}}
+		}
+		var keys []string
+		for key := range definition {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			f.bind(key, definition[key], location)
+		}
+		return nil
+	}
+	if !filepath.IsAbs(value) {
+		value = filepath.Join(filepath.Dir(path), value)
+	}
+	for _, other := range including {
+		if filepath.Clean(other) == value {
+			return ParseErrors{{Source: path, LineNumber: lineNumber, Message: fmt.Sprintf("%s includes itself", value)}}
+		}
+	}
+	var errs, err = f.load(value, including)
+	if err != nil {
+		return append(errs, &ParseError{Source: path, LineNumber: lineNumber, Message: err.Error()})
+	}
+	return errs
+}
+
+func (f *InputMethodFile) bind(key, line string, location fileLocation) {
+	if _, found := f.Definition[key]; !found {
+		f.Keys = append(f.Keys, key)
+	}
+	f.Definition[key] = line
+	f.locations[key] = location
+}
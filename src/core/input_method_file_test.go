@@ -0,0 +1,158 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "telex-im")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		var path = filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadInputMethodFile(t *testing.T) {
+	var dir = writeTestFiles(t, map[string]string{
+		"plus.im": `# Telex with brackets
+@name        Telex Plus
+@author      Nguyễn Văn A
+@description Telex where [ and ] type ơ and ư
+@include     Telex
+@include     brackets.im   # shared with other methods
+w            UOA_ƯƠĂ       # no lone ư
+\#           DauNga
+`,
+		"brackets.im": `@name Brackets
+[ __ơ
+] __ư
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	var f, err = LoadInputMethodFile(filepath.Join(dir, "plus.im"))
+	if err != nil {
+		t.Fatalf("Test loading a definition file. Got %v", err)
+	}
+	if f.Name != "Telex Plus" || f.Author != "Nguyễn Văn A" || !strings.HasPrefix(f.Description, "Telex where") {
+		t.Errorf("Test the metadata of a definition file. Got %q, %q, %q", f.Name, f.Author, f.Description)
+	}
+	if f.Definition["w"] != "UOA_ƯƠĂ" || f.Definition["["] != "__ơ" || f.Definition["#"] != "DauNga" || f.Definition["s"] != "DauSac" {
+		t.Errorf("Test the bindings of a definition file. Got %v", f.Definition)
+	}
+	if last := f.Keys[len(f.Keys)-1]; last != "#" {
+		t.Errorf("Test the order of the bindings. Got %q last", last)
+	}
+	var im, _ = f.Parse()
+	var e = NewEngine(im, EstdFlags)
+	e.ProcessString("t[f", VietnameseMode)
+	if got := e.GetProcessedString(VietnameseMode); got != "tờ" {
+		t.Errorf("Process t[f with a definition file. Got %s, expected tờ", got)
+	}
+}
+
+func TestLoadInputMethodFileIncludeAfterBinding(t *testing.T) {
+	var dir = writeTestFiles(t, map[string]string{
+		"late.im": `w UOA_ƯƠĂ__Ư
+[ __ơ
+@include Telex
+@include brackets.im
+`,
+		"brackets.im": "[ __Ơ\n] __ư\n",
+	})
+	defer os.RemoveAll(dir)
+
+	var f, err = LoadInputMethodFile(filepath.Join(dir, "late.im"))
+	if err != nil {
+		t.Fatalf("Test loading a definition file. Got %v", err)
+	}
+	if f.Definition["w"] != "UOA_ƯƠĂ__Ư" || f.Definition["["] != "__ơ" || f.Definition["]"] != "__ư" {
+		t.Errorf("Bindings should override the ones of later includes. Got %v", f.Definition)
+	}
+	if location := f.locations["w"]; location.line != 1 {
+		t.Errorf("Test the location of an overriding binding. Got line %d", location.line)
+	}
+}
+
+func TestLoadInputMethodFileErrors(t *testing.T) {
+	var dir = writeTestFiles(t, map[string]string{
+		"broken.im": `@name Broken
+@include Unknown
+@include self.im
+@color red
+s DauSac
+s DauHuyen
+a A_Ê
+f
+`,
+		"self.im": "@include self.im\n",
+	})
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "broken.im")
+	var f, err = LoadInputMethodFile(path)
+	if f == nil || err == nil {
+		t.Fatalf("Test loading a broken definition file. Got %v, %v", f, err)
+	}
+	var expected = []string{
+		path + ":2: ",
+		filepath.Join(dir, "self.im") + ":1: ",
+		path + ":4: ",
+		path + ":6: ",
+		path + ":8: ",
+	}
+	var errs = err.(ParseErrors)
+	if len(errs) != len(expected) {
+		t.Errorf("Test the number of errors in a definition file. Got %v", err)
+	}
+	for i, prefix := range expected {
+		if i < len(errs) && !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Errorf("Test the location of an error. Got %q, expected prefix %q", errs[i], prefix)
+		}
+	}
+	if _, err = f.Parse(); err == nil || !strings.HasPrefix(err.Error(), path+":7: Broken: key \"a\"") {
+		t.Errorf("Test the location of a rule error. Got %v", err)
+	}
+	if _, err = LoadInputMethodFile(filepath.Join(dir, "missing.im")); err == nil {
+		t.Errorf("Test loading a missing definition file. Expected an error")
+	}
+}
+
+func TestLoadInputMethodDirs(t *testing.T) {
+	var system = writeTestFiles(t, map[string]string{
+		"a.im": "@name Mine\ns DauSac\n",
+		"b.im": "@name Other\nf DauHuyen\n",
+	})
+	defer os.RemoveAll(system)
+	var user = writeTestFiles(t, map[string]string{
+		"mine.im": "s DauHuyen\n@name Mine\n",
+	})
+	defer os.RemoveAll(user)
+
+	var files, err = LoadInputMethodDirs(system, user, filepath.Join(user, "missing"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Test loading definition directories. Got %v, %v", files, err)
+	}
+	if files[0].Name != "Mine" || files[0].Definition["s"] != "DauHuyen" {
+		t.Errorf("User files should override system ones. Got %v", files[0])
+	}
+}
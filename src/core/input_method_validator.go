@@ -0,0 +1,200 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "info"
+}
+
+// Issue is a problem found by ValidateInputMethod. Key is 0 when the issue is
+// about the input method as a whole.
+type Issue struct {
+	Severity Severity
+	Key      rune
+	Message  string
+}
+
+func (i Issue) String() string {
+	if i.Key == 0 {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: key %q: %s", i.Severity, i.Key, i.Message)
+}
+
+var toneNames = map[Tone]string{
+	ToneNone:  "XoaDauThanh",
+	ToneAcute: "DauSac",
+	ToneGrave: "DauHuyen",
+	ToneHook:  "DauHoi",
+	ToneTilde: "DauNga",
+	ToneDot:   "DauNang",
+}
+
+// Letters of the Vietnamese alphabet, an appending rule on one of them
+// makes the plain letter hard to type.
+var vietnameseLetters = []rune("abcdeghiklmnopqrstuvxy")
+
+var markedLetters = []rune("âăêôơưđ")
+
+// ValidateInputMethod looks for conflicting, redundant and missing rules in an
+// input method. Issues are sorted by severity, most severe first.
+func ValidateInputMethod(im InputMethod) []Issue {
+	var issues []Issue
+	var rulesByKey = map[rune][]Rule{}
+	for _, rule := range im.Rules {
+		rulesByKey[rule.Key] = append(rulesByKey[rule.Key], rule)
+		// the leading keys of a key sequence rule do nothing on their own
+		for _, key := range rule.KeySequence {
+			if _, ok := rulesByKey[key]; !ok {
+				rulesByKey[key] = nil
+			}
+		}
+	}
+	var toneKeys = map[Tone][]rune{}
+	var markKeys = map[[2]rune][]rune{}
+	for _, key := range im.Keys {
+		var rules, ok = rulesByKey[key]
+		if !ok {
+			issues = append(issues, Issue{SeverityWarning, key, "the key has no rules"})
+			continue
+		}
+		var hasTone, hasMark, hasAppending bool
+		for _, rule := range rules {
+			switch rule.EffectType {
+			case ToneTransformation:
+				hasTone = true
+				toneKeys[rule.GetTone()] = appendKey(toneKeys[rule.GetTone()], key)
+			case MarkTransformation:
+				hasMark = true
+				if rule.Effect > 0 {
+					var effect = [2]rune{AddToneToChar(rule.EffectOn, 0), AddToneToChar(rule.Result, 0)}
+					markKeys[effect] = appendKey(markKeys[effect], key)
+				}
+			case Appending:
+				hasAppending = true
+			}
+		}
+		if hasTone && (hasMark || hasAppending) {
+			issues = append(issues, Issue{SeverityError, key, "the key is both a tone key and a mark key"})
+		}
+		if hasAppending && inKeyList(vietnameseLetters, key) {
+			issues = append(issues, Issue{SeverityWarning, key, fmt.Sprintf("the appending rule shadows the letter %q", key)})
+		}
+	}
+	for tone, keys := range toneKeys {
+		if len(keys) > 1 {
+			issues = append(issues, Issue{SeverityInfo, keys[1], fmt.Sprintf("also produced by %q: %s", keys[0], toneNames[tone])})
+		}
+	}
+	var duplicatedMarks = map[[2]rune][]string{}
+	for effect, keys := range markKeys {
+		for _, key := range keys[1:] {
+			var pair = [2]rune{key, keys[0]}
+			duplicatedMarks[pair] = append(duplicatedMarks[pair], fmt.Sprintf("%c->%c", effect[0], effect[1]))
+		}
+	}
+	for pair, effects := range duplicatedMarks {
+		sort.Strings(effects)
+		issues = append(issues, Issue{SeverityInfo, pair[0], fmt.Sprintf("also produced by %q: %s", pair[1], strings.Join(effects, ", "))})
+	}
+	issues = append(issues, validateReachability(im, toneKeys)...)
+	issues = append(issues, validateSuperKeys(im, rulesByKey)...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Severity != issues[j].Severity {
+			return issues[i].Severity > issues[j].Severity
+		}
+		if issues[i].Key != issues[j].Key {
+			return issues[i].Key < issues[j].Key
+		}
+		return issues[i].Message < issues[j].Message
+	})
+	return issues
+}
+
+func validateReachability(im InputMethod, toneKeys map[Tone][]rune) []Issue {
+	var issues []Issue
+	for _, tone := range []Tone{ToneAcute, ToneGrave, ToneHook, ToneTilde, ToneDot} {
+		if len(toneKeys[tone]) == 0 {
+			issues = append(issues, Issue{SeverityWarning, 0, fmt.Sprintf("no key produces %s", toneNames[tone])})
+		}
+	}
+	var reachable = map[rune]bool{}
+	for _, rule := range im.Rules {
+		switch rule.EffectType {
+		case MarkTransformation:
+			reachable[AddToneToChar(rule.Result, 0)] = true
+		case Replacing:
+			for _, chr := range rule.Replacement {
+				reachable[chr] = true
+			}
+		case Appending:
+			reachable[unicode.ToLower(rule.EffectOn)] = true
+			for _, appendedRule := range rule.AppendedRules {
+				reachable[unicode.ToLower(appendedRule.EffectOn)] = true
+			}
+		}
+	}
+	for _, chr := range markedLetters {
+		if !reachable[chr] {
+			issues = append(issues, Issue{SeverityWarning, 0, fmt.Sprintf("%q can not be typed", chr)})
+		}
+	}
+	return issues
+}
+
+// The uow shortcut only ever uses the first super key, and only if that key
+// puts a horn on both 'u' and 'o'.
+func validateSuperKeys(im InputMethod, rulesByKey map[rune][]Rule) []Issue {
+	var issues []Issue
+	for i, key := range im.SuperKeys {
+		if i > 0 {
+			issues = append(issues, Issue{SeverityInfo, key, fmt.Sprintf("the uow shortcut only uses the first super key %q", im.SuperKeys[0])})
+			continue
+		}
+		var hornOnU, hornOnO bool
+		for _, rule := range rulesByKey[key] {
+			if rule.EffectType == MarkTransformation && rule.GetMark() == MarkHorn {
+				hornOnU = hornOnU || rule.EffectOn == 'u'
+				hornOnO = hornOnO || rule.EffectOn == 'o'
+			}
+		}
+		if !hornOnU || !hornOnO {
+			issues = append(issues, Issue{SeverityWarning, key, "the uow shortcut needs a super key that turns both u and o into ư and ơ"})
+		}
+	}
+	return issues
+}
+
+func appendKey(keys []rune, key rune) []rune {
+	if inKeyList(keys, key) {
+		return keys
+	}
+	return append(keys, key)
+}
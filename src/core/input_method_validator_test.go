@@ -0,0 +1,74 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"testing"
+)
+
+func TestValidateBuiltinInputMethods(t *testing.T) {
+	for name := range InputMethodDefinitions {
+		for _, issue := range ValidateInputMethod(ParseInputMethod(InputMethodDefinitions, name)) {
+			if issue.Severity > SeverityInfo {
+				t.Errorf("Test validating %s. Got %s", name, issue)
+			}
+		}
+	}
+}
+
+func TestValidateInputMethod(t *testing.T) {
+	var im = InputMethod{Name: "Broken"}
+	for _, key := range []rune("sfwnv") {
+		im.Keys = append(im.Keys, key)
+	}
+	im.Rules = append(im.Rules, ParseRules('s', "DauSac")...)
+	im.Rules = append(im.Rules, ParseRules('f', "DauSac")...)
+	im.Rules = append(im.Rules, ParseRules('f', "A_Â")...)
+	im.Rules = append(im.Rules, ParseRules('w', "O_Ơ")...)
+	im.Rules = append(im.Rules, ParseRules('n', "__ư")...)
+	im.SuperKeys = []rune{'w'}
+
+	var expected = []Issue{
+		{SeverityError, 'f', "the key is both a tone key and a mark key"},
+		{SeverityWarning, 'n', "the appending rule shadows the letter 'n'"},
+		{SeverityWarning, 'v', "the key has no rules"},
+		{SeverityWarning, 'w', "the uow shortcut needs a super key that turns both u and o into ư and ơ"},
+		{SeverityInfo, 'f', "also produced by 's': DauSac"},
+	}
+	var issues = ValidateInputMethod(im)
+	for _, issue := range expected {
+		var found = false
+		for _, got := range issues {
+			if got == issue {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Test validating a broken input method. Missing %s in %v", issue, issues)
+		}
+	}
+	for _, message := range []string{"'ă' can not be typed", "no key produces DauHuyen"} {
+		var found = false
+		for _, got := range issues {
+			if got.Severity == SeverityWarning && got.Key == 0 && got.Message == message {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Test validating a broken input method. Missing warning %q in %v", message, issues)
+		}
+	}
+
+	im = ParseInputMethod(map[string]InputMethodDefinition{"Sequences": {"uoz": "ươ", "s": "DauSac"}}, "Sequences")
+	for _, issue := range ValidateInputMethod(im) {
+		if issue.Key == 'u' || issue.Key == 'o' || issue.Message == "'ư' can not be typed" {
+			t.Errorf("Test validating a key sequence rule. Got %s", issue)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// A Lexicon is a set of real Vietnamese syllables that the engine checks typed
+// syllables against when EspellCheckWithDicts is set. A lexicon file has one
+// syllable or word per line, the syllables of a word being added one by one,
+// and lines starting with # are comments. Syllables match whatever the tone
+// placement style, e.g. "hoà" and "hòa", or whether they are decomposed.
+type Lexicon struct {
+	syllables map[string]bool
+}
+
+func NewLexicon() *Lexicon {
+	return &Lexicon{syllables: map[string]bool{}}
+}
+
+// LoadLexicon reads the given lexicon files into a single lexicon, skipping the
+// ones that do not exist.
+func LoadLexicon(paths ...string) (*Lexicon, error) {
+	var l = NewLexicon()
+	return l, loadListFiles(paths, l.Read)
+}
+
+// Read adds the entries of a lexicon file.
+func (l *Lexicon) Read(r io.Reader) error {
+	return readListFile(r, l.Add)
+}
+
+// Add adds the syllables of a word.
+func (l *Lexicon) Add(word string) {
+	for _, syllable := range strings.Fields(word) {
+		l.syllables[getLexiconKey(syllable)] = true
+	}
+}
+
+func (l *Lexicon) HasSyllable(syllable string) bool {
+	return l != nil && l.syllables[getLexiconKey(syllable)]
+}
+
+// Len returns the number of syllables, a nil lexicon being empty.
+func (l *Lexicon) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.syllables)
+}
+
+// A WordList is a set of words compared regardless of case, such as the
+// English words that auto-restore keeps as typed. Its files have the same
+// format as lexicon files.
+type WordList map[string]bool
+
+// LoadWordList reads the given word list files, skipping the ones that do not
+// exist.
+func LoadWordList(paths ...string) (WordList, error) {
+	var l = WordList{}
+	return l, loadListFiles(paths, l.Read)
+}
+
+// Read adds the words of a word list file.
+func (l WordList) Read(r io.Reader) error {
+	return readListFile(r, func(line string) {
+		l.Add(strings.Fields(line)...)
+	})
+}
+
+func (l WordList) Add(words ...string) {
+	for _, word := range words {
+		l[strings.ToLower(word)] = true
+	}
+}
+
+func (l WordList) Remove(words ...string) {
+	for _, word := range words {
+		delete(l, strings.ToLower(word))
+	}
+}
+
+func (l WordList) Has(word string) bool {
+	return l[strings.ToLower(word)]
+}
+
+func loadListFiles(paths []string, read func(io.Reader) error) error {
+	for _, path := range paths {
+		var f, err = os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = read(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// readListFile calls add with every line of r that is neither empty nor a
+// comment.
+func readListFile(r io.Reader, add func(string)) error {
+	var scanner = bufio.NewScanner(r)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		add(line)
+	}
+	return scanner.Err()
+}
+
+// getLexiconKey writes the tone after the toneless syllable so that both tone
+// placement styles, as well as decomposed letters, give the same key.
+func getLexiconKey(syllable string) string {
+	var tone = ToneNone
+	var key []rune
+	for _, chr := range strings.ToLower(Decode(UnicodeNFD, syllable)) {
+		if t := FindToneFromChar(chr); t != ToneNone {
+			tone = t
+		}
+		key = append(key, AddToneToChar(chr, 0))
+	}
+	if tone != ToneNone {
+		key = append(key, rune('0'+tone))
+	}
+	return string(key)
+}
+
+// isLexiconWord reports whether word only holds letters, so that numbers and
+// symbols are not looked up.
+func isLexiconWord(word string) bool {
+	for _, chr := range word {
+		if !unicode.IsLetter(chr) {
+			return false
+		}
+	}
+	return word != ""
+}
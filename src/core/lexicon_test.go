@@ -0,0 +1,127 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLexicon(t *testing.T) {
+	var l = NewLexicon()
+	var err = l.Read(strings.NewReader("# syllables\nviệt\nhòa bình\n\n  Người  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != 4 {
+		t.Errorf("Lexicon length. Got %d, expected 4", l.Len())
+	}
+	var tests = []struct {
+		syllable string
+		expected bool
+	}{
+		{"việt", true},
+		{"Việt", true},
+		{"viet", false},
+		{"hòa", true},
+		{"hoà", true},
+		{"bình", true},
+		{"người", true},
+		{"syllables", false},
+		{"vie\u0323\u0302t", true}, // decomposed
+	}
+	for _, test := range tests {
+		if got := l.HasSyllable(test.syllable); got != test.expected {
+			t.Errorf("HasSyllable %s. Got %v, expected %v", test.syllable, got, test.expected)
+		}
+	}
+	var nilLexicon *Lexicon
+	if nilLexicon.Len() != 0 || nilLexicon.HasSyllable("việt") {
+		t.Errorf("A nil lexicon must be empty")
+	}
+}
+
+func TestWordList(t *testing.T) {
+	var l = WordList{}
+	var err = l.Read(strings.NewReader("# English\nkeep deep\nWrong\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("Data")
+	l.Remove("deep")
+	var tests = []struct {
+		word     string
+		expected bool
+	}{
+		{"keep", true},
+		{"KEEP", true},
+		{"wrong", true},
+		{"data", true},
+		{"deep", false},
+		{"English", false},
+	}
+	for _, test := range tests {
+		if got := l.Has(test.word); got != test.expected {
+			t.Errorf("Has %s. Got %v, expected %v", test.word, got, test.expected)
+		}
+	}
+}
+
+func TestShippedEnglishWordList(t *testing.T) {
+	var l, err = LoadWordList("../../data/english.txt")
+	if err != nil || !l.Has("keep") {
+		t.Fatalf("Load the shipped English word list. Got %v", err)
+	}
+	var tests = []struct {
+		input    string
+		expected string
+		restored bool
+	}{
+		{"as", "á", true},
+		{"mix", "mĩ", true},
+		{"keep", "kêp", true},
+		{"born", "bỏn", true},
+		{"this", "thí", false},
+		{"roots", "rốt", false},
+		{"lost", "lót", false},
+		{"sets", "sét", false},
+		{"hits", "hít", false},
+		{"chair", "chải", false},
+		{"arm", "ảm", false},
+		{"vary", "vảy", false},
+		{"trust", "trút", false},
+		{"list", "lít", false},
+		{"caps", "cáp", false},
+		{"cups", "cúp", false},
+		{"must", "mút", false},
+		{"tax", "tã", false},
+		{"test", "tét", false},
+		{"bits", "bít", false},
+		{"if", "ì", false},
+		{"is", "í", false},
+		{"tree", "trê", false},
+		{"sense", "sến", false},
+		{"room", "rôm", false},
+		{"seen", "sên", false},
+		{"noon", "nôn", false},
+		{"six", "sĩ", false},
+		{"there", "thể", false},
+		{"queen", "quên", false},
+	}
+	for _, test := range tests {
+		var e = NewEngine(ParseInputMethod(InputMethodDefinitions, "Telex"), EstdFlags)
+		e.ProcessString(test.input, VietnameseMode)
+		if got := e.GetProcessedString(VietnameseMode); got != test.expected {
+			t.Errorf("Process %s. Got %s, expected %s", test.input, got, test.expected)
+		}
+		if got := l.Has(e.GetProcessedString(EnglishMode)); got != test.restored {
+			t.Errorf("Restore %s as English. Got %v, expected %v", test.input, got, test.restored)
+		}
+	}
+}
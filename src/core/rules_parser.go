@@ -9,8 +9,12 @@
 package core
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 var tones = map[string]Tone{
@@ -54,6 +58,15 @@ const (
 	ToneDot   Tone = iota
 )
 
+// RulePosition restricts where in a syllable a rule may fire.
+type RulePosition uint8
+
+const (
+	PositionAnywhere RulePosition = iota << 0
+	PositionStart    RulePosition = iota // the rule's keys begin the syllable
+	PositionEnd      RulePosition = iota // the key follows a complete syllable
+)
+
 type Rule struct {
 	Key           rune
 	Effect        uint8 // (Tone, Mark)
@@ -61,6 +74,12 @@ type Rule struct {
 	EffectOn      rune
 	Result        rune
 	AppendedRules []Rule
+	// KeySequence holds the keys typed right before Key in a multi-key rule,
+	// e.g. "uo" for "uow", and Replacement is what the whole sequence becomes.
+	KeySequence []rune
+	Replacement []rune
+	Position    RulePosition
+	Priority    int // the highest priority wins when several rules match
 }
 
 func (r *Rule) SetTone(tone Tone) {
@@ -88,81 +107,299 @@ type InputMethod struct {
 	Keys          []rune
 }
 
+// ParseError describes an entry of an input method definition that could not
+// be parsed. Source and LineNumber are only set for definition files.
+type ParseError struct {
+	InputMethod string
+	Key         string
+	Line        string
+	Message     string
+	Source      string
+	LineNumber  int
+}
+
+func (e *ParseError) Error() string {
+	var location string
+	if e.Source != "" && e.LineNumber > 0 {
+		location = fmt.Sprintf("%s:%d: ", e.Source, e.LineNumber)
+	} else if e.Source != "" {
+		location = e.Source + ": "
+	}
+	var name = e.InputMethod
+	if name == "" {
+		name = "input method"
+	}
+	if e.Key == "" && e.Line == "" {
+		return fmt.Sprintf("%s%s: %s", location, name, e.Message)
+	}
+	if e.Key == "" {
+		return fmt.Sprintf("%s%s: %s (%q)", location, name, e.Message, e.Line)
+	}
+	return fmt.Sprintf("%s%s: key %q: %s (%q)", location, name, e.Key, e.Message, e.Line)
+}
+
+// ParseErrors holds every problem found while parsing an input method.
+type ParseErrors []*ParseError
+
+func (errs ParseErrors) Error() string {
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (errs ParseErrors) withInputMethod(name string) ParseErrors {
+	for _, err := range errs {
+		err.InputMethod = name
+	}
+	return errs
+}
+
 func ParseInputMethod(imDef map[string]InputMethodDefinition, imName string) InputMethod {
-	var inputMethods = parseInputMethods(imDef)
-	if inputMethod, found := inputMethods[imName]; found {
-		return inputMethod
+	var im, _ = ParseInputMethodWithErrors(imDef, imName)
+	return im
+}
+
+// ParseInputMethodWithErrors parses the input method named imName like
+// ParseInputMethod does, but also returns a ParseErrors describing an unknown
+// name and every definition entry that was dropped or only partially parsed.
+func ParseInputMethodWithErrors(imDef map[string]InputMethodDefinition, imName string) (InputMethod, error) {
+	var definition, found = findInputMethodDefinition(imDef, imName)
+	if !found {
+		return InputMethod{}, ParseErrors{{InputMethod: imName, Message: "unknown input method"}}
 	}
-	return InputMethod{}
+	var im, errs = parseInputMethod(imName, definition)
+	if len(errs) > 0 {
+		return im, errs
+	}
+	return im, nil
 }
 
-func parseInputMethods(imDef map[string]InputMethodDefinition) map[string]InputMethod {
-	var inputMethods = make(map[string]InputMethod, len(imDef))
-	for name, imDefinition := range imDef {
-		var im InputMethod
-		im.Name = name
-		for keyStr, line := range imDefinition {
-			var keys = []rune(keyStr)
-			if len(keys) == 0 {
-				continue
-			}
-			var key = keys[0]
-			im.Rules = append(im.Rules, ParseRules(key, line)...)
-			if strings.Contains(strings.ToLower(line), "uo") {
-				im.SuperKeys = append(im.SuperKeys, key)
-			}
-			im.Keys = append(im.Keys, key)
+func parseInputMethod(name string, imDefinition InputMethodDefinition) (InputMethod, ParseErrors) {
+	var im InputMethod
+	var errs ParseErrors
+	im.Name = name
+	var keyStrs []string
+	for keyStr := range imDefinition {
+		keyStrs = append(keyStrs, keyStr)
+	}
+	sort.Strings(keyStrs)
+	for _, keyStr := range keyStrs {
+		var line = imDefinition[keyStr]
+		var keys = []rune(keyStr)
+		if len(keys) == 0 {
+			errs = append(errs, &ParseError{Key: keyStr, Line: line, Message: "a key can not be empty"})
+			continue
 		}
-		for _, rule := range im.Rules {
-			if rule.EffectType == Appending {
-				im.AppendingKeys = append(im.AppendingKeys, rule.Key)
+		if len(keys) > 1 {
+			var rules, err = ParseKeySequenceRulesWithErrors(keys, line)
+			if err != nil {
+				errs = append(errs, err.(ParseErrors)...)
 			}
-			if rule.EffectType == ToneTransformation {
-				im.ToneKeys = append(im.ToneKeys, rule.Key)
+			im.Rules = append(im.Rules, rules...)
+			for _, key := range keys {
+				im.Keys = appendKey(im.Keys, unicode.ToLower(key))
 			}
+			continue
+		}
+		var key = keys[0]
+		var rules, err = ParseRulesWithErrors(key, line)
+		if err != nil {
+			errs = append(errs, err.(ParseErrors)...)
 		}
-		inputMethods[name] = im
+		im.Rules = append(im.Rules, rules...)
+		if strings.Contains(strings.ToLower(line), "uo") {
+			im.SuperKeys = append(im.SuperKeys, key)
+		}
+		im.Keys = appendKey(im.Keys, key)
 	}
-	return inputMethods
+	for _, rule := range im.Rules {
+		if rule.EffectType == Appending {
+			im.AppendingKeys = append(im.AppendingKeys, rule.Key)
+		}
+		if rule.EffectType == ToneTransformation {
+			im.ToneKeys = append(im.ToneKeys, rule.Key)
+		}
+	}
+	return im, errs.withInputMethod(name)
 }
 
 func ParseRules(key rune, line string) []Rule {
+	var rules, _ = ParseRulesWithErrors(key, line)
+	return rules
+}
+
+// ParseRulesWithErrors parses the rules of a single definition entry and
+// returns a ParseErrors for the parts of line that had to be skipped.
+//
+// A line may end with options separated by semicolons: "start" or "end" to
+// only fire at the start or the end of a syllable, and "priority=N" to win
+// over other rules of the same key, e.g. "__ư;start;priority=1".
+func ParseRulesWithErrors(key rune, line string) ([]Rule, error) {
 	var rules []Rule
-	if tone, ok := tones[line]; ok {
+	var body, options = splitRuleOptions(line)
+	var position, priority, messages = parseRuleOptions(options)
+	if tone, ok := tones[body]; ok {
 		var rule Rule
 		rule.Key = key
 		rule.EffectType = ToneTransformation
 		rule.Effect = uint8(tone)
 		rules = append(rules, rule)
 	} else {
-		rules = ParseTonelessRules(key, line)
+		var tonelessMessages []string
+		rules, tonelessMessages = parseTonelessRules(key, body)
+		messages = append(messages, tonelessMessages...)
 	}
+	for i := range rules {
+		rules[i].Position = position
+		rules[i].Priority = priority
+	}
+	return rules, newParseErrors(string(key), line, messages)
+}
+
+func ParseKeySequenceRules(keys []rune, line string) []Rule {
+	var rules, _ = ParseKeySequenceRulesWithErrors(keys, line)
 	return rules
 }
 
+// ParseKeySequenceRulesWithErrors parses a multi-key entry such as
+// "uow": "ươ", where line holds the letters the typed keys turn into. Each of
+// the leading keys must be a letter that can be marked into the matching
+// replacement letter, and the replacement may end with one more letter that
+// is appended by the last key. The same options as ParseRulesWithErrors are
+// accepted.
+func ParseKeySequenceRulesWithErrors(keys []rune, line string) ([]Rule, error) {
+	var body, options = splitRuleOptions(line)
+	var position, priority, messages = parseRuleOptions(options)
+	var lowerKeys = []rune(strings.ToLower(string(keys)))
+	var sequence = lowerKeys[:len(lowerKeys)-1]
+	var replacement = []rune(strings.ToLower(body))
+	if !regKeySequenceReplacement.MatchString(body) {
+		messages = append(messages, fmt.Sprintf("%q is not a replacement text", body))
+	} else if len(replacement) != len(sequence) && len(replacement) != len(sequence)+1 {
+		messages = append(messages, fmt.Sprintf("%q must have %d or %d letters", body, len(sequence), len(sequence)+1))
+	} else {
+		var changed = len(replacement) > len(sequence)
+		for i, key := range sequence {
+			if replacement[i] == key {
+				continue
+			}
+			if !inKeyList(getMarkFamily(key), replacement[i]) {
+				messages = append(messages, fmt.Sprintf("%q can not be made from %q", replacement[i], key))
+			}
+			changed = true
+		}
+		if !changed {
+			messages = append(messages, "the keys are left unchanged")
+		}
+	}
+	if len(messages) > 0 {
+		return nil, newParseErrors(string(keys), line, messages)
+	}
+	var rule = Rule{
+		Key:         lowerKeys[len(lowerKeys)-1],
+		EffectType:  Replacing,
+		KeySequence: sequence,
+		Replacement: replacement,
+		Position:    position,
+		Priority:    priority,
+	}
+	return []Rule{rule}, nil
+}
+
+var regKeySequenceReplacement = regexp.MustCompile(`^\p{L}+$`)
+
+func newParseErrors(key, line string, messages []string) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	var errs ParseErrors
+	for _, message := range messages {
+		errs = append(errs, &ParseError{Key: key, Line: line, Message: message})
+	}
+	return errs
+}
+
+func splitRuleOptions(line string) (string, []string) {
+	var parts = strings.Split(line, ";")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts[0], parts[1:]
+}
+
+func parseRuleOptions(options []string) (RulePosition, int, []string) {
+	var position = PositionAnywhere
+	var priority int
+	var messages []string
+	for _, option := range options {
+		switch {
+		case option == "start":
+			position = PositionStart
+		case option == "end":
+			position = PositionEnd
+		case strings.HasPrefix(option, "priority="):
+			var n, err = strconv.Atoi(strings.TrimPrefix(option, "priority="))
+			if err != nil {
+				messages = append(messages, fmt.Sprintf("%q is not a valid priority", option))
+				continue
+			}
+			priority = n
+		default:
+			messages = append(messages, fmt.Sprintf("unknown option %q", option))
+		}
+	}
+	return position, priority, messages
+}
+
 var regDsl = regexp.MustCompile(`([a-zA-Z]+)_(\p{L}+)([_\p{L}]*)`)
 
 func ParseTonelessRules(key rune, line string) []Rule {
+	var rules, _ = parseTonelessRules(key, line)
+	return rules
+}
+
+func parseTonelessRules(key rune, line string) ([]Rule, []string) {
 	var rules []Rule
+	var messages []string
 	if regDsl.MatchString(line) {
 		parts := regDsl.FindStringSubmatch(strings.ToLower(line))
+		if parts[0] != strings.ToLower(line) {
+			messages = append(messages, fmt.Sprintf("unexpected characters around %q", parts[0]))
+		}
 		effectiveOns := []rune(parts[1])
 		results := []rune(parts[2])
+		if len(effectiveOns) != len(results) {
+			messages = append(messages, fmt.Sprintf("%q and %q must have the same length", parts[1], parts[2]))
+		}
 		for i, effectiveOn := range effectiveOns {
+			if i >= len(results) {
+				break
+			}
 			effect, found := FindMarkFromChar(results[i])
 			if !found {
+				messages = append(messages, fmt.Sprintf("%q is not a marked letter", results[i]))
+				continue
+			}
+			if !inKeyList(getMarkFamily(effectiveOn), results[i]) {
+				messages = append(messages, fmt.Sprintf("%q can not be made from %q", results[i], effectiveOn))
 				continue
 			}
 			rules = append(rules, ParseToneLessRule(key, effectiveOn, results[i], effect)...)
 		}
 		if rule, ok := getAppendingRule(key, parts[3]); ok {
 			rules = append(rules, rule)
+		} else if parts[3] != "" {
+			messages = append(messages, fmt.Sprintf("%q is not an appending rule", parts[3]))
 		}
 
 	} else if rule, ok := getAppendingRule(key, line); ok {
 		rules = append(rules, rule)
+	} else {
+		messages = append(messages, "neither a tone nor a mark or appending rule")
 	}
-	return rules
+	return rules, messages
 }
 
 func ParseToneLessRule(key, effectiveOn, result rune, effect Mark) []Rule {
@@ -138,4 +138,118 @@ func TestAppendRule(t *testing.T) {
 }
 
 func TestParseRulesWithIm(t *testing.T) {
+	var tests = []struct {
+		name          string
+		toneKeys      string
+		appendingKeys string
+		superKeys     string
+	}{
+		{"Telex", "zsfrxj", "", "w"},
+		{"Simple Telex", "zsfrxj", "", "w"},
+		{"Telex 2", "zsfrxj", "w[]{}", "w"},
+		{"VNI", "012345", "", "7"},
+		{"VIQR", "'`?~.", "", "+"},
+		{"Telex + VNI", "zsfrxj012345", "", "w7"},
+	}
+	for _, test := range tests {
+		var im = ParseInputMethod(InputMethodDefinitions, test.name)
+		if im.Name != test.name {
+			t.Errorf("Test parsing input method %s. Got name %q", test.name, im.Name)
+			continue
+		}
+		if len(im.Keys) != len(InputMethodDefinitions[test.name]) {
+			t.Errorf("Test the keys of %s. Got %d, expected %d", test.name, len(im.Keys), len(InputMethodDefinitions[test.name]))
+		}
+		for _, keys := range []struct {
+			kind     string
+			got      []rune
+			expected string
+		}{
+			{"tone keys", im.ToneKeys, test.toneKeys},
+			{"appending keys", im.AppendingKeys, test.appendingKeys},
+			{"super keys", im.SuperKeys, test.superKeys},
+		} {
+			if len(keys.got) != len([]rune(keys.expected)) {
+				t.Errorf("Test the %s of %s. Got %q, expected %q", keys.kind, test.name, string(keys.got), keys.expected)
+				continue
+			}
+			for _, key := range keys.expected {
+				if !inKeyList(keys.got, key) {
+					t.Errorf("Test the %s of %s. Got %q, missing %q", keys.kind, test.name, string(keys.got), key)
+				}
+			}
+		}
+	}
+}
+
+func TestParseInputMethodWithErrors(t *testing.T) {
+	var imDefs = map[string]InputMethodDefinition{
+		"Broken": {
+			"s":  "DauSak",
+			"w":  "UOA_ƯƠ",
+			"a":  "A_Ê",
+			"dd": "D_Đ",
+			"j":  "DauNang",
+		},
+	}
+	var im, err = ParseInputMethodWithErrors(imDefs, "Broken")
+	if err == nil {
+		t.Fatalf("Test parsing a broken input method. Expected errors")
+	}
+	var errs = err.(ParseErrors)
+	if len(errs) != 4 {
+		t.Errorf("Test the number of parse errors. Got %d, expected %d: %v", len(errs), 4, err)
+	}
+	for _, e := range errs {
+		if e.InputMethod != "Broken" || e.Key == "" {
+			t.Errorf("Test the location of a parse error. Got %v", e)
+		}
+	}
+	if !inKeyList(im.ToneKeys, 'j') || !inKeyList(im.Keys, 'w') {
+		t.Errorf("Valid entries should still be parsed. Got %v", im)
+	}
+	if _, err = ParseInputMethodWithErrors(imDefs, "Unknown"); err == nil {
+		t.Errorf("Test parsing an unknown input method. Expected an error")
+	}
+	for name := range InputMethodDefinitions {
+		if _, err = ParseInputMethodWithErrors(InputMethodDefinitions, name); err != nil {
+			t.Errorf("Test parsing the built-in input method %s. Got %v", name, err)
+		}
+	}
+}
+
+func TestParseRuleOptions(t *testing.T) {
+	var rules, err = ParseRulesWithErrors('w', "__ư;start;priority=2")
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("Test parsing a rule with options. Got %v, %v", rules, err)
+	}
+	if rules[0].Position != PositionStart || rules[0].Priority != 2 || rules[0].Result != 'ư' {
+		t.Errorf("Test the options of a rule. Got %v", rules[0])
+	}
+	if rules = ParseRules('x', "DauNga; end"); len(rules) != 1 || rules[0].Position != PositionEnd {
+		t.Errorf("Test parsing a tone rule with options. Got %v", rules)
+	}
+	if _, err = ParseRulesWithErrors('x', "DauNga;middle;priority=high"); err == nil || len(err.(ParseErrors)) != 2 {
+		t.Errorf("Test parsing unknown options. Got %v", err)
+	}
+}
+
+func TestParseKeySequenceRules(t *testing.T) {
+	var rules = ParseKeySequenceRules([]rune("uow"), "ươ")
+	if len(rules) != 1 {
+		t.Fatalf("Test parsing a key sequence rule. Got %v", rules)
+	}
+	var rule = rules[0]
+	if rule.Key != 'w' || rule.EffectType != Replacing || string(rule.KeySequence) != "uo" || string(rule.Replacement) != "ươ" {
+		t.Errorf("Test parsing a key sequence rule. Got %v", rule)
+	}
+	for _, line := range []string{"A_Â", "ư", "ương", "uo", "ưa"} {
+		if _, err := ParseKeySequenceRulesWithErrors([]rune("uow"), line); err == nil {
+			t.Errorf("Test parsing the key sequence rule %q. Expected an error", line)
+		}
+	}
+	var im = ParseInputMethod(map[string]InputMethodDefinition{"Sequences": {"uoz": "ươ", "s": "DauSac"}}, "Sequences")
+	if string(im.Keys) != "suoz" {
+		t.Errorf("Test the keys of a key sequence rule. Got %q", string(im.Keys))
+	}
 }
@@ -10,6 +10,7 @@
 package core
 
 import (
+	"sort"
 	"unicode"
 )
 
@@ -28,9 +29,15 @@ const (
 const (
 	EstdToneStyle uint = 1 << iota
 	EautoCorrectEnabled
-	EstdFlags = EstdToneStyle | EautoCorrectEnabled
+	EspellCheckWithDicts // complete syllables must also be in the lexicon
+	EspellCheckDisabled  // IsValid accepts anything
+	EstdFlags            = EstdToneStyle | EautoCorrectEnabled
 )
 
+// EscapeKey makes the punctuation effect key typed right after it literal in
+// input methods whose tone keys are punctuation marks, e.g. "ra\." in VIQR.
+const EscapeKey = '\\'
+
 type Transformation struct {
 	Rule        Rule
 	Target      *Transformation
@@ -45,15 +52,18 @@ type IEngine interface {
 	GetProcessedString(Mode) string
 	IsValid(bool) bool
 	CanProcessKey(rune) bool
+	IsWordBreakSymbol(rune) bool
 	RemoveLastChar(bool)
 	RestoreLastWord()
 	Reset()
+	SetLexicon(*Lexicon)
 }
 
 type TelexEngine struct {
 	composition []*Transformation
 	inputMethod InputMethod
 	flags       uint
+	lexicon     *Lexicon
 }
 
 func NewEngine(inputMethod InputMethod, flag uint) IEngine {
@@ -76,6 +86,10 @@ func (e *TelexEngine) GetFlag(flag uint) uint {
 	return e.flags
 }
 
+func (e *TelexEngine) SetLexicon(lexicon *Lexicon) {
+	e.lexicon = lexicon
+}
+
 func (e *TelexEngine) isSuperKey(lowerKey rune) bool {
 	return inKeyList(e.GetInputMethod().SuperKeys, lowerKey)
 }
@@ -88,9 +102,53 @@ func (e *TelexEngine) isEffectiveKey(key rune) bool {
 	return inKeyList(e.GetInputMethod().Keys, key)
 }
 
+// hasPunctuationToneKeys reports whether the input method types tones with
+// punctuation marks (VIQR), which makes the escape key available. Methods with
+// only a few punctuation mark keys, e.g. the brackets of "Telex 2", keep the
+// backslash as a word break.
+func (e *TelexEngine) hasPunctuationToneKeys() bool {
+	for _, key := range e.GetInputMethod().ToneKeys {
+		if IsPunctuationMark(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEscaped reports whether the last key typed is a pending escape key.
+func (e *TelexEngine) isEscaped() bool {
+	if len(e.composition) == 0 || !e.hasPunctuationToneKeys() {
+		return false
+	}
+	var last = e.composition[len(e.composition)-1]
+	return last.Rule.EffectType == Appending && last.Rule.Key == EscapeKey
+}
+
+func (e *TelexEngine) hasAlphaEffectKeys() bool {
+	for _, key := range e.GetInputMethod().Keys {
+		if IsAlpha(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid reports whether the last word is Vietnamese. It checks the
+// consonant and vowel combination and, for a complete word in dictionary
+// mode, that the lexicon has it. An empty lexicon leaves the rules alone.
 func (e *TelexEngine) IsValid(inputIsFullComplete bool) bool {
+	if e.flags&EspellCheckDisabled != 0 {
+		return true
+	}
 	var _, last = extractLastWord(e.composition, e.GetInputMethod().Keys)
-	return isValid(last, inputIsFullComplete)
+	if !isValid(last, inputIsFullComplete) {
+		return false
+	}
+	if e.flags&EspellCheckWithDicts != 0 && inputIsFullComplete && e.lexicon.Len() > 0 {
+		var word = Flatten(last, VietnameseMode|LowerCase)
+		return !isLexiconWord(word) || e.lexicon.HasSyllable(word)
+	}
+	return true
 }
 
 func (e *TelexEngine) GetProcessedString(mode Mode) string {
@@ -103,30 +161,81 @@ func (e *TelexEngine) GetProcessedString(mode Mode) string {
 	return Flatten(tmp, mode)
 }
 
-func (e *TelexEngine) getApplicableRules(key rune) []Rule {
+// getApplicableRules returns the rules of key whose position constraint holds
+// for a key typed after composition, the highest priority first.
+func (e *TelexEngine) getApplicableRules(composition []*Transformation, key rune) []Rule {
 	var applicableRules []Rule
 	for _, inputRule := range e.inputMethod.Rules {
-		if inputRule.Key == unicode.ToLower(key) {
+		if inputRule.Key == unicode.ToLower(key) && isApplicableAt(composition, inputRule) {
 			applicableRules = append(applicableRules, inputRule)
 		}
 	}
+	sort.SliceStable(applicableRules, func(i, j int) bool {
+		return applicableRules[i].Priority > applicableRules[j].Priority
+	})
 	return applicableRules
 }
 
 func (e *TelexEngine) findTargetByKey(composition []*Transformation, key rune) (*Transformation, Rule) {
-	return findTarget(composition, e.getApplicableRules(key), e.flags)
+	return findTarget(composition, e.getApplicableRules(composition, key), e.flags)
 }
 
 func (e *TelexEngine) CanProcessKey(key rune) bool {
+	if key == EscapeKey && e.hasPunctuationToneKeys() {
+		return true
+	}
 	return canProcessKey(key, e.inputMethod.Keys)
 }
 
+// IsWordBreakSymbol reports whether key ends the current word. Effect keys of
+// the input method (e.g. the VNI digits) only break a word when none of their
+// rules applies to the last syllable, so numbers like "2020" are left as is.
+// Punctuation effect keys (VIQR) must directly follow the letter they modify,
+// so a period typed after a closed syllable still ends the sentence. After an
+// open syllable the period is the dot below tone ("ra." gives "rạ"); a literal
+// period is typed either by repeating it ("ra.." gives "ra.") or, as in
+// RFC 1456, by escaping it with a backslash ("ra\." gives "ra.").
+// When an input method mixes letter and digit effect keys (e.g. "Telex + VNI"),
+// a digit is a tone or mark key only right after a vowel-bearing syllable and
+// a literal digit anywhere else.
+func (e *TelexEngine) IsWordBreakSymbol(key rune) bool {
+	var lowerKey = unicode.ToLower(key)
+	if !IsWordBreakSymbol(lowerKey) {
+		return false
+	}
+	if lowerKey == EscapeKey && e.hasPunctuationToneKeys() {
+		return false
+	}
+	if e.isEscaped() && e.isEffectiveKey(lowerKey) {
+		return false
+	}
+	if !e.isEffectiveKey(lowerKey) {
+		return true
+	}
+	if inKeyList(e.inputMethod.AppendingKeys, lowerKey) {
+		return false
+	}
+	var _, syllable = extractLastSyllable(e.composition)
+	if unicode.IsDigit(lowerKey) && e.hasAlphaEffectKeys() && !hasVowel(syllable) {
+		return true
+	}
+	var transformations = generateTransformations(syllable, e.getApplicableRules(syllable, lowerKey), e.flags, lowerKey, false)
+	if len(transformations) == 0 {
+		return true
+	}
+	if IsPunctuationMark(lowerKey) && transformations[0].Target != nil {
+		return findRootTarget(transformations[0].Target) != findLastAppendingTrans(syllable)
+	}
+	return false
+}
+
 func (e *TelexEngine) generateTransformations(composition []*Transformation, lowerKey rune, isUpperCase bool) []*Transformation {
-	var transformations = generateTransformations(composition, e.getApplicableRules(lowerKey), e.flags, lowerKey, isUpperCase)
+	var applicableRules = e.getApplicableRules(composition, lowerKey)
+	var transformations = generateTransformations(composition, applicableRules, e.flags, lowerKey, isUpperCase)
 	if transformations == nil {
 		// If none of the applicable_rules can actually be applied then this new
 		// transformation fall-backs to an APPENDING one.
-		transformations = generateFallbackTransformations(composition, e.getApplicableRules(lowerKey), lowerKey, isUpperCase)
+		transformations = generateFallbackTransformations(composition, applicableRules, lowerKey, isUpperCase)
 		var newComposition = append(composition, transformations...)
 
 		// Implement the uwo+ typing shortcut by creating a virtual
@@ -179,7 +288,12 @@ func (e *TelexEngine) ProcessString(str string, mode Mode) {
 func (e *TelexEngine) ProcessKey(key rune, mode Mode) {
 	var lowerKey = unicode.ToLower(key)
 	var isUpperCase = unicode.IsUpper(key)
-	if mode&EnglishMode != 0 || !e.CanProcessKey(lowerKey) {
+	if mode&EnglishMode == 0 && e.isEscaped() && (lowerKey == EscapeKey || e.isEffectiveKey(lowerKey)) {
+		// the escaped key replaces the escape key and stays literal
+		e.composition[len(e.composition)-1] = newAppendingTrans(lowerKey, isUpperCase)
+		return
+	}
+	if mode&EnglishMode != 0 || !e.CanProcessKey(lowerKey) || e.IsWordBreakSymbol(lowerKey) {
 		if mode&InReverseOrder != 0 {
 			e.composition = append([]*Transformation{newAppendingTrans(lowerKey, isUpperCase)}, e.composition...)
 			return
@@ -0,0 +1,317 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"testing"
+)
+
+func newTestEngine(imName string) IEngine {
+	return NewEngine(ParseInputMethod(InputMethodDefinitions, imName), EstdFlags)
+}
+
+func TestProcessTelexVariants(t *testing.T) {
+	var tests = []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Telex", "w", "w"},
+		{"Telex", "new", "new"},
+		{"Telex", "tuwf", "từ"},
+		{"Simple Telex", "w", "w"},
+		{"Simple Telex", "tuwf", "từ"},
+		{"Telex 2", "w", "ư"},
+		{"Telex 2", "t[f", "tờ"},
+		{"Telex 2", "]", "ư"},
+		{"Telex 2", "}", "Ư"},
+		{"Telex 2", "{", "Ơ"},
+	}
+	for _, test := range tests {
+		var e = newTestEngine(test.name)
+		e.ProcessString(test.input, VietnameseMode)
+		if got := e.GetProcessedString(VietnameseMode); got != test.expected {
+			t.Errorf("Process %s with %s. Got %s, expected %s", test.input, test.name, got, test.expected)
+		}
+	}
+}
+
+func TestProcessVNI(t *testing.T) {
+	var tests = map[string]string{
+		"vie65t":  "việt",
+		"viet65":  "việt",
+		"nguoi72": "người",
+		"tieng61": "tiếng",
+		"d9":      "đ",
+		"a22":     "a2",
+	}
+	for input, expected := range tests {
+		var e = newTestEngine("VNI")
+		e.ProcessString(input, VietnameseMode)
+		if got := e.GetProcessedString(VietnameseMode); got != expected {
+			t.Errorf("Process %s with VNI. Got %s, expected %s", input, got, expected)
+		}
+	}
+}
+
+func TestVNIWordBreak(t *testing.T) {
+	var e = newTestEngine("VNI")
+	if !e.IsWordBreakSymbol('2') {
+		t.Errorf("A digit with nothing to apply to should be a word break")
+	}
+	e.ProcessString("nam", VietnameseMode)
+	if e.IsWordBreakSymbol('2') {
+		t.Errorf("A VNI tone key after a syllable should not be a word break")
+	}
+	if !e.IsWordBreakSymbol('.') {
+		t.Errorf("Punctuation marks should still be word breaks")
+	}
+	e = newTestEngine("Telex")
+	e.ProcessString("nam", VietnameseMode)
+	if !e.IsWordBreakSymbol('2') {
+		t.Errorf("Digits should be word breaks in Telex")
+	}
+}
+
+func TestProcessVIQR(t *testing.T) {
+	var tests = map[string]string{
+		"Vie^.t":    "Việt",
+		"ngu+o+`i":  "người",
+		"dda('ng":   "đắng",
+		"ddu+o+`ng": "đường",
+		"ta.":       "tạ",
+		"ta..":      "ta.",
+		"ra\\.":     "ra.",
+		"Ha\\.":     "Ha.",
+	}
+	for input, expected := range tests {
+		var e = newTestEngine("VIQR")
+		e.ProcessString(input, VietnameseMode)
+		if got := e.GetProcessedString(VietnameseMode); got != expected {
+			t.Errorf("Process %s with VIQR. Got %s, expected %s", input, got, expected)
+		}
+	}
+}
+
+func TestVIQRWordBreak(t *testing.T) {
+	var e = newTestEngine("VIQR")
+	e.ProcessString("Nam", VietnameseMode)
+	if !e.IsWordBreakSymbol('.') {
+		t.Errorf("A period after a closed syllable should end the sentence")
+	}
+	e.Reset()
+	e.ProcessString("ra", VietnameseMode)
+	if e.IsWordBreakSymbol('.') {
+		t.Errorf("A period after an open syllable should be the dot below tone")
+	}
+	if e.IsWordBreakSymbol(EscapeKey) {
+		t.Errorf("The escape key should not be a word break")
+	}
+	e.ProcessKey(EscapeKey, VietnameseMode)
+	if e.IsWordBreakSymbol('.') {
+		t.Errorf("An escaped period should be kept in the word")
+	}
+	e.ProcessKey('.', VietnameseMode)
+	if got := e.GetProcessedString(VietnameseMode); got != "ra." {
+		t.Errorf("Process ra\\. with VIQR. Got %s, expected ra.", got)
+	}
+	if !e.IsWordBreakSymbol(' ') {
+		t.Errorf("A space after an escaped period should end the word")
+	}
+	e.Reset()
+	e.ProcessString("vie", VietnameseMode)
+	if e.IsWordBreakSymbol('^') {
+		t.Errorf("A VIQR mark key right after its vowel should not be a word break")
+	}
+	if !e.IsWordBreakSymbol(',') {
+		t.Errorf("Punctuation marks which are not effect keys should be word breaks")
+	}
+}
+
+func TestEscapeKeyOnlyInVIQR(t *testing.T) {
+	var e = newTestEngine("Telex 2")
+	e.ProcessString("a", VietnameseMode)
+	if e.CanProcessKey(EscapeKey) || !e.IsWordBreakSymbol(EscapeKey) {
+		t.Errorf("The backslash should stay a word break in Telex 2")
+	}
+	// the word "a" ends, the next effect key applies as usual
+	e.Reset()
+	e.ProcessString("w", VietnameseMode)
+	if got := e.GetProcessedString(VietnameseMode); got != "ư" {
+		t.Errorf("Process a\\w with Telex 2. Got %s after the backslash, expected ư", got)
+	}
+}
+
+func TestProcessTelexAndVNI(t *testing.T) {
+	var tests = map[string]string{
+		"vieetj":  "việt",
+		"viet65":  "việt",
+		"nguowi2": "người",
+		"ddaays":  "đấy",
+	}
+	for input, expected := range tests {
+		var e = newTestEngine("Telex + VNI")
+		e.ProcessString(input, VietnameseMode)
+		if got := e.GetProcessedString(VietnameseMode); got != expected {
+			t.Errorf("Process %s with Telex + VNI. Got %s, expected %s", input, got, expected)
+		}
+	}
+	var e = newTestEngine("Telex + VNI")
+	for _, input := range []string{"", "x", "d", "2"} {
+		e.Reset()
+		e.ProcessString(input, VietnameseMode)
+		if !e.IsWordBreakSymbol('9') {
+			t.Errorf("A digit after %q should be a literal digit", input)
+		}
+	}
+	e.Reset()
+	e.ProcessString("di", VietnameseMode)
+	if e.IsWordBreakSymbol('9') {
+		t.Errorf("A digit after a vowel-bearing syllable should be an effect key")
+	}
+}
+
+func TestParseCombinedInputMethod(t *testing.T) {
+	var imDefs = map[string]InputMethodDefinition{
+		"Tones": {"s": "DauSac", "f": "DauHuyen"},
+		"Marks": {"a": "A_Â", "s": "A_Ă"},
+	}
+	var im = ParseInputMethod(imDefs, "Tones + Marks")
+	if im.Name != "Tones + Marks" || len(im.Keys) != 3 {
+		t.Fatalf("Test parsing a combined input method. Got %v", im)
+	}
+	if !inKeyList(im.ToneKeys, 's') {
+		t.Errorf("The first definition should win a key conflict. Got tone keys %q", string(im.ToneKeys))
+	}
+	if im = ParseInputMethod(imDefs, "Tones + Unknown"); im.Name != "" {
+		t.Errorf("Combining an unknown definition should fail. Got %v", im)
+	}
+}
+
+func TestProcessContextRules(t *testing.T) {
+	var imDefs = map[string]InputMethodDefinition{
+		"Context": {
+			"s":   "DauSac",
+			"f":   "DauHuyen",
+			"x":   "DauNga;end",
+			"w":   "__ư;start",
+			"ow":  "ơ",
+			"uoz": "ươ",
+			"dd":  "đ",
+			"aa":  "â",
+		},
+		"Priority": {
+			"f":   "DauHuyen",
+			"uoz": "uô",
+			"z":   "UO_ƯƠ;priority=1",
+		},
+	}
+	var tests = []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Context", "nguozif", "người"},
+		{"Context", "ddaats", "đất"},
+		{"Context", "tows", "tớ"},
+		{"Context", "wf", "ừ"},
+		{"Context", "tw", "tw"},
+		{"Context", "max", "mã"},
+		{"Context", "thuox", "thuox"},
+		{"Priority", "nguoz", "ngươ"},
+	}
+	for _, test := range tests {
+		var e = NewEngine(ParseInputMethod(imDefs, test.name), EstdFlags)
+		e.ProcessString(test.input, VietnameseMode)
+		if got := e.GetProcessedString(VietnameseMode); got != test.expected {
+			t.Errorf("Process %s with %s. Got %s, expected %s", test.input, test.name, got, test.expected)
+		}
+		if got := e.GetProcessedString(EnglishMode); got != test.input {
+			t.Errorf("Test the raw string of %s with %s. Got %s", test.input, test.name, got)
+		}
+	}
+	var e = NewEngine(ParseInputMethod(map[string]InputMethodDefinition{"Sequence": {"uoz": "uô", "z": "UO_ƯƠ"}}, "Sequence"), EstdFlags)
+	e.ProcessString("nguoz", VietnameseMode)
+	if got := e.GetProcessedString(VietnameseMode); got != "nguô" {
+		t.Errorf("A key sequence rule should win over a single key rule of the same priority. Got %s", got)
+	}
+}
+
+func TestSpellCheckModes(t *testing.T) {
+	var lexicon = NewLexicon()
+	lexicon.Add("quyên việt")
+	var tests = []struct {
+		flags    uint
+		input    string
+		expected bool
+	}{
+		{EstdFlags, "quyeen", true},
+		{EstdFlags, "quyeeng", true},
+		{EstdFlags, "bank", false},
+		{EstdFlags | EspellCheckWithDicts, "quyeen", true},
+		{EstdFlags | EspellCheckWithDicts, "quyeeng", false},
+		{EstdFlags | EspellCheckWithDicts, "vieejt", true},
+		{EstdFlags | EspellCheckWithDicts, "bank", false},
+		{EstdFlags | EspellCheckDisabled, "bank", true},
+	}
+	for _, test := range tests {
+		var e = NewEngine(ParseInputMethod(InputMethodDefinitions, "Telex"), test.flags)
+		e.SetLexicon(lexicon)
+		e.ProcessString(test.input, VietnameseMode)
+		if got := e.IsValid(true); got != test.expected {
+			t.Errorf("IsValid %s with flags %d. Got %v, expected %v", test.input, test.flags, got, test.expected)
+		}
+	}
+	// the phonotactic rules still apply to incomplete words
+	var e = NewEngine(ParseInputMethod(InputMethodDefinitions, "Telex"), EstdFlags|EspellCheckWithDicts)
+	e.SetLexicon(lexicon)
+	e.ProcessString("quyeen", VietnameseMode)
+	if !e.IsValid(false) {
+		t.Errorf("IsValid quyeen while typing. Got false, expected true")
+	}
+}
+
+func TestIsValidWithShippedLexicon(t *testing.T) {
+	var lexicon, err = LoadLexicon("../../data/vietnamese.txt")
+	if err != nil || lexicon.Len() == 0 {
+		t.Fatalf("Load the shipped lexicon. Got %d syllables, %v", lexicon.Len(), err)
+	}
+	var tests = []struct {
+		input    string
+		expected bool
+	}{
+		{"quyeeng", false},
+		{"choens", false},
+		{"queeus", false},
+		{"term", false},
+		{"norm", false},
+		{"peer", false},
+		{"gherm", false},
+		{"kengs", false},
+		{"bonr", false},
+		{"quyeenf", true},
+		{"nguwowfi", true},
+		{"chuyeenr", true},
+		{"nghieeng", true},
+		{"gif", true},
+		{"quoocs", true},
+		{"ghees", true},
+		{"hoaf", true},
+		{"hofa", true},
+		{"tieengs", true},
+	}
+	for _, test := range tests {
+		var e = NewEngine(ParseInputMethod(InputMethodDefinitions, "Telex"), EstdFlags|EspellCheckWithDicts)
+		e.SetLexicon(lexicon)
+		e.ProcessString(test.input, VietnameseMode)
+		if got := e.IsValid(true); got != test.expected {
+			t.Errorf("IsValid %s with the shipped lexicon. Got %v, expected %v", test.input, got, test.expected)
+		}
+	}
+}
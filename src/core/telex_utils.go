@@ -9,6 +9,7 @@
 package core
 
 import (
+	"math"
 	"regexp"
 	"unicode"
 )
@@ -88,6 +89,15 @@ func isValid(composition []*Transformation, inputIsFullComplete bool) bool {
 	return isValidCVC(Flatten(fc, flattenMode), Flatten(vo, flattenMode), Flatten(lc, flattenMode), inputIsFullComplete)
 }
 
+func hasVowel(composition []*Transformation) bool {
+	for _, chr := range Flatten(composition, VietnameseMode|LowerCase) {
+		if IsVowel(chr) {
+			return true
+		}
+	}
+	return false
+}
+
 func getRightMostVowels(composition []*Transformation) []*Transformation {
 	var _, vo, _ = extractCvcTrans(composition)
 	return vo
@@ -295,7 +305,23 @@ func findMarkTarget(composition []*Transformation, rules []Rule) (*Transformatio
 	return nil, Rule{}
 }
 
+// findTarget tries the applicable rules one priority at a time, so a rule
+// with a higher priority wins even if a lower one has a closer target.
 func findTarget(composition []*Transformation, applicableRules []Rule, flags uint) (*Transformation, Rule) {
+	for len(applicableRules) > 0 {
+		var n = 1
+		for n < len(applicableRules) && applicableRules[n].Priority == applicableRules[0].Priority {
+			n++
+		}
+		if target, rule := findTargetWithSamePriority(composition, applicableRules[:n], flags); target != nil {
+			return target, rule
+		}
+		applicableRules = applicableRules[n:]
+	}
+	return nil, Rule{}
+}
+
+func findTargetWithSamePriority(composition []*Transformation, applicableRules []Rule, flags uint) (*Transformation, Rule) {
 	var str = Flatten(composition, VietnameseMode)
 	// find tone target
 	for _, applicableRule := range applicableRules {
@@ -391,6 +417,20 @@ func generateTransformations(composition []*Transformation, applicableRules []Ru
 			return transformations
 		}
 	}
+	// Multi-key rules are more specific than single-key ones of the same priority,
+	// so they go first, e.g. u + o + w -> ươ
+	var singleKeyPriority = math.MinInt32
+	for _, rule := range applicableRules {
+		if rule.EffectType != Replacing {
+			singleKeyPriority = rule.Priority
+			break
+		}
+	}
+	if transformations = applyKeySequenceRules(composition, applicableRules, isUpperCase, func(priority int) bool {
+		return priority >= singleKeyPriority
+	}); transformations != nil {
+		return transformations
+	}
 	// A target may be applied by many different transformations, e.g. o + o + w -> ơ
 	if target, applicableRule := findTarget(composition, applicableRules, flags); target != nil {
 		transformations = append(transformations, &Transformation{
@@ -433,6 +473,11 @@ func generateTransformations(composition []*Transformation, applicableRules []Ru
 				return transformations
 			}
 		}
+		if transformations = applyKeySequenceRules(composition, applicableRules, isUpperCase, func(priority int) bool {
+			return priority < singleKeyPriority
+		}); transformations != nil {
+			return transformations
+		}
 		if undoTrans := generateUndoTransformations(composition, applicableRules, flags); len(undoTrans) > 0 {
 			// If an effect key can't find its target, it tries to undo its effects, e.g. ươ + w -> uow
 			transformations = append(transformations, undoTrans...)
@@ -442,6 +487,97 @@ func generateTransformations(composition []*Transformation, applicableRules []Ru
 	return transformations
 }
 
+// isApplicableAt reports whether the position constraint of rule holds for its
+// key typed after composition.
+func isApplicableAt(composition []*Transformation, rule Rule) bool {
+	switch rule.Position {
+	case PositionStart:
+		return len(filterTypedAppendingComposition(composition)) == len(rule.KeySequence)
+	case PositionEnd:
+		return len(composition) > 0 && isValid(composition, true)
+	}
+	return true
+}
+
+// filterTypedAppendingComposition returns the appending transformations that
+// come from a typed key, leaving out the virtual ones.
+func filterTypedAppendingComposition(composition []*Transformation) []*Transformation {
+	var appendingTransformations []*Transformation
+	for _, trans := range filterAppendingComposition(composition) {
+		if trans.Rule.Key != 0 {
+			appendingTransformations = append(appendingTransformations, trans)
+		}
+	}
+	return appendingTransformations
+}
+
+func applyKeySequenceRules(composition []*Transformation, rules []Rule, isUpperCase bool, hasPriority func(int) bool) []*Transformation {
+	for _, rule := range rules {
+		if rule.EffectType != Replacing || !hasPriority(rule.Priority) {
+			continue
+		}
+		if transformations := applyKeySequenceRule(composition, rule, isUpperCase); transformations != nil {
+			return transformations
+		}
+	}
+	return nil
+}
+
+// applyKeySequenceRule turns the letters typed by the key sequence of rule into
+// its replacement, provided the composition ends with that sequence. The
+// first transformation carries the key so it shows up in the raw string.
+func applyKeySequenceRule(composition []*Transformation, rule Rule, isUpperCase bool) []*Transformation {
+	var appendings = filterTypedAppendingComposition(composition)
+	var n = len(rule.KeySequence)
+	if len(appendings) < n {
+		return nil
+	}
+	var sequence = appendings[len(appendings)-n:]
+	for i, trans := range sequence {
+		if trans.Rule.Key != rule.KeySequence[i] {
+			return nil
+		}
+	}
+	var transformations []*Transformation
+	for i, trans := range sequence {
+		var chars = []*Transformation{trans}
+		for _, t := range composition {
+			if t.Target == trans && t.Rule.EffectType == MarkTransformation {
+				chars = append(chars, t)
+			}
+		}
+		if []rune(Flatten(chars, VietnameseMode|ToneLess|LowerCase))[0] == rule.Replacement[i] {
+			continue
+		}
+		var mark, _ = FindMarkFromChar(rule.Replacement[i])
+		transformations = append(transformations, &Transformation{
+			Rule: Rule{
+				EffectType: MarkTransformation,
+				Effect:     uint8(mark),
+				EffectOn:   trans.Rule.Result,
+				Result:     rule.Replacement[i],
+			},
+			Target: trans,
+		})
+	}
+	if len(rule.Replacement) > n {
+		var chr = rule.Replacement[n]
+		transformations = append(transformations, &Transformation{
+			Rule: Rule{
+				EffectType: Appending,
+				EffectOn:   chr,
+				Result:     chr,
+			},
+			IsUpperCase: isUpperCase,
+		})
+	}
+	if len(transformations) == 0 || !isValid(append(composition, transformations...), false) {
+		return nil
+	}
+	transformations[0].Rule.Key = rule.Key
+	return transformations
+}
+
 func generateFallbackTransformations(composition []*Transformation, applicableRules []Rule, lowerKey rune, isUpperCase bool) []*Transformation {
 	var transformations []*Transformation
 	var trans = generateAppendingTrans(applicableRules, lowerKey, isUpperCase)
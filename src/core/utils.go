@@ -9,6 +9,7 @@
 package core
 
 import (
+	"strings"
 	"unicode"
 )
 
@@ -116,6 +117,22 @@ func AddMarkToChar(chr rune, mark uint8) rune {
 	return AddToneToChar(chr, uint8(tone))
 }
 
+// RemoveAccents strips the tones and marks of Vietnamese letters, keeping their
+// case, e.g. "Đường" becomes "Duong".
+func RemoveAccents(str string) string {
+	return strings.Map(func(chr rune) rune {
+		var lowerChr = unicode.ToLower(chr)
+		var base = AddMarkToTonelessChar(AddToneToChar(lowerChr, 0), 0)
+		if base == lowerChr {
+			return chr
+		}
+		if unicode.IsUpper(chr) {
+			return unicode.ToUpper(base)
+		}
+		return base
+	}, str)
+}
+
 func IsAlpha(c rune) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
 }
@@ -0,0 +1,144 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This software is licensed under the MIT license. For more information,
+ * see <https://github.com/andodevel/ibus-telex/src/core/blob/master/LICENSE>.
+ */
+
+package core
+
+import (
+	"strings"
+	"unicode"
+)
+
+// VIQR (RFC 1456) writes a letter as its base letter followed by its mark and
+// its tone, e.g. "Vie^.t Nam", and đ as dd. A diacritic character that must
+// stay literal right after a letter is escaped with a backslash, e.g. "Ha\."
+// at the end of a sentence, and so is a literal backslash.
+var viqrMarks = map[Mark]rune{
+	MarkHat:   '^',
+	MarkBreve: '(',
+	MarkHorn:  '+',
+}
+
+var viqrTones = map[Tone]rune{
+	ToneAcute: '\'',
+	ToneGrave: '`',
+	ToneHook:  '?',
+	ToneTilde: '~',
+	ToneDot:   '.',
+}
+
+// viqrLetter is the letter being decoded, to which the following diacritic
+// characters may still attach.
+type viqrLetter struct {
+	base rune // lowercase, 0 when there is no letter to attach to
+	mark Mark
+	tone Tone
+}
+
+// attach returns the letter with chr attached, or false if chr stays literal.
+func (l viqrLetter) attach(chr rune) (viqrLetter, bool) {
+	if l.base == 0 {
+		return l, false
+	}
+	if l.base == 'd' && l.mark == MarkNone && unicode.ToLower(chr) == 'd' {
+		l.mark = MarkDash
+		return l, true
+	}
+	for mark, c := range viqrMarks {
+		if c == chr && l.mark == MarkNone && l.tone == ToneNone && AddMarkToTonelessChar(l.base, uint8(mark)) != l.base {
+			l.mark = mark
+			return l, true
+		}
+	}
+	for tone, c := range viqrTones {
+		if c == chr && l.tone == ToneNone && IsVowel(l.base) {
+			l.tone = tone
+			return l, true
+		}
+	}
+	return l, false
+}
+
+func (l viqrLetter) rune(isUpperCase bool) rune {
+	var chr = AddToneToChar(AddMarkToTonelessChar(l.base, uint8(l.mark)), uint8(l.tone))
+	if isUpperCase {
+		return unicode.ToUpper(chr)
+	}
+	return chr
+}
+
+func isVIQRSpecial(chr rune) bool {
+	return chr == '\\' || chr == 'd' || chr == 'D' || strings.ContainsRune("^(+'`?~.", chr)
+}
+
+func encodeVIQR(input string) string {
+	var b strings.Builder
+	var last viqrLetter
+	for _, chr := range input {
+		var lowerChr = unicode.ToLower(chr)
+		var toneless = AddToneToChar(lowerChr, 0)
+		var base = AddMarkToTonelessChar(toneless, 0)
+		if _, attached := last.attach(chr); attached || chr == '\\' {
+			// a literal that would modify the previous letter, e.g. "Ha\."
+			b.WriteRune('\\')
+			b.WriteRune(chr)
+			last = viqrLetter{}
+			continue
+		}
+		if !IsAlpha(base) {
+			b.WriteRune(chr)
+			last = viqrLetter{}
+			continue
+		}
+		var mark, _ = FindMarkFromChar(toneless)
+		var tone = FindToneFromChar(lowerChr)
+		last = viqrLetter{base: base, mark: mark, tone: tone}
+		var letter = base
+		if unicode.IsUpper(chr) {
+			letter = unicode.ToUpper(base)
+		}
+		b.WriteRune(letter)
+		if c, found := viqrMarks[mark]; found {
+			b.WriteRune(c)
+		} else if mark == MarkDash {
+			b.WriteRune(letter)
+		}
+		if c, found := viqrTones[tone]; found {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func decodeVIQR(input string) string {
+	var b strings.Builder
+	var runes = []rune(input)
+	for i := 0; i < len(runes); i++ {
+		var chr = runes[i]
+		if chr == '\\' && i+1 < len(runes) && isVIQRSpecial(runes[i+1]) {
+			b.WriteRune(runes[i+1])
+			i++
+			continue
+		}
+		var lowerChr = unicode.ToLower(chr)
+		if !IsAlpha(lowerChr) {
+			b.WriteRune(chr)
+			continue
+		}
+		var letter = viqrLetter{base: lowerChr}
+		for i+1 < len(runes) {
+			var next, attached = letter.attach(runes[i+1])
+			if !attached {
+				break
+			}
+			letter = next
+			i++
+		}
+		b.WriteRune(letter.rune(unicode.IsUpper(chr)))
+	}
+	return b.String()
+}
@@ -0,0 +1,188 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/BambooEngine/goibus/ibus"
+	"github.com/godbus/dbus"
+)
+
+// A candidate is a choice of a candidate window, text being what is committed
+// and label what is shown.
+type candidate struct {
+	text  string
+	label string
+}
+
+// A candidateSource finds the candidates of what is typed in a candidate
+// window, e.g. the emojis of a shortcode.
+type candidateSource interface {
+	findCandidates(query string) []candidate
+	// isQueryKey reports whether key is part of a query rather than a key that
+	// closes the window.
+	isQueryKey(key rune) bool
+	// selectsWithDigits reports whether digit keys pick a candidate of the page
+	// rather than being part of a query.
+	selectsWithDigits() bool
+}
+
+const candidatePageSize = 9
+
+// A candidateWindow shows the candidates of what is typed until one of them
+// is committed. A window opened by typing a prefix, e.g. ':' for emojis,
+// commits the prefix and the query as they are when it is closed otherwise,
+// and typing the prefix again commits the selected candidate.
+type candidateWindow struct {
+	source     candidateSource
+	title      string // shown before the query
+	prefix     string
+	query      []rune
+	candidates []candidate
+	table      *ibus.LookupTable
+}
+
+func (e *IBusTelex) openCandidateWindow(source candidateSource, title, prefix string) {
+	e.hideCompletions()
+	e.preeditor.Reset()
+	e.candidateWindow = &candidateWindow{source: source, title: title, prefix: prefix}
+	e.updateCandidateWindow()
+}
+
+// getTypedText returns what to commit when no candidate is chosen.
+func (w *candidateWindow) getTypedText() string {
+	if w.prefix == "" {
+		return ""
+	}
+	return w.prefix + string(w.query)
+}
+
+// refresh finds the candidates of the query again.
+func (w *candidateWindow) refresh() {
+	w.candidates = nil
+	if len(w.query) > 0 {
+		w.candidates = w.source.findCandidates(string(w.query))
+	}
+	w.table = ibus.NewLookupTable()
+	w.table.PageSize = candidatePageSize
+	for _, c := range w.candidates {
+		w.table.AppendCandidate(c.label)
+	}
+}
+
+// updateCandidateWindow finds the candidates of the query again and shows
+// them, with the query as the auxiliary text.
+func (e *IBusTelex) updateCandidateWindow() {
+	var w = e.candidateWindow
+	w.refresh()
+	e.UpdateAuxiliaryText(ibus.NewText(w.title+string(w.query)), true)
+	e.showCandidateTable(w)
+}
+
+func (e *IBusTelex) showCandidateTable(w *candidateWindow) {
+	e.UpdateLookupTable(w.table, len(w.candidates) > 0)
+}
+
+// getShownWindow returns the window whose candidates are shown, if any: the
+// candidate window, or else the completions of the word being typed.
+func (e *IBusTelex) getShownWindow() *candidateWindow {
+	if e.candidateWindow != nil {
+		return e.candidateWindow
+	}
+	return e.completionWindow
+}
+
+// closeCandidateWindow closes the window and commits text, which is not
+// learned.
+func (e *IBusTelex) closeCandidateWindow(text string) {
+	e.candidateWindow = nil
+	e.UpdateLookupTable(ibus.NewLookupTable(), true) // workaround for issue #18
+	e.HideLookupTable()
+	e.HideAuxiliaryText()
+	if text == "" {
+		return
+	}
+	if e.checkInputMode(preeditIM) {
+		e.commitExpansion(text)
+	} else {
+		e.SendText([]rune(text))
+	}
+}
+
+// commitCandidate commits the candidate under the cursor, if any, or what
+// was typed.
+func (e *IBusTelex) commitCandidate() {
+	var w = e.candidateWindow
+	if pos := int(w.table.CursorPos); pos < len(w.candidates) {
+		e.closeCandidateWindow(w.candidates[pos].text)
+		return
+	}
+	e.closeCandidateWindow(w.getTypedText())
+}
+
+func (e *IBusTelex) cwProcessKeyEvent(keyVal uint32, keyCode uint32, state uint32) (bool, *dbus.Error) {
+	var w = e.candidateWindow
+	var keyRune = rune(keyVal)
+	var typed = w.getTypedText()
+	if !e.isValidState(state) {
+		e.closeCandidateWindow(typed)
+		return false, nil
+	}
+	switch {
+	case keyVal == IBusEscape:
+		e.closeCandidateWindow(typed)
+	case keyVal == IBusBackSpace && len(w.query) == 0:
+		e.closeCandidateWindow("")
+	case keyVal == IBusBackSpace:
+		w.query = w.query[:len(w.query)-1]
+		e.updateCandidateWindow()
+	case keyVal == IBusLeft || keyVal == IBusUp:
+		e.CursorUp()
+	case keyVal == IBusRight || keyVal == IBusDown:
+		e.CursorDown()
+	case keyVal == IBusPageUp:
+		e.PageUp()
+	case keyVal == IBusPageDown:
+		e.PageDown()
+	case keyVal == IBusReturn && int(w.table.CursorPos) >= len(w.candidates):
+		// e.g. ":wq", the application gets the Return key
+		e.closeCandidateWindow(typed)
+		return false, nil
+	case keyVal == IBusReturn:
+		e.commitCandidate()
+	case w.prefix != "" && string(keyRune) == w.prefix && len(w.candidates) > 0:
+		// e.g. the closing colon of ":smile:"
+		e.commitCandidate()
+	case keyRune >= '1' && keyRune <= '9' && w.source.selectsWithDigits():
+		var pos = w.table.CursorPos/w.table.PageSize*w.table.PageSize + uint32(keyRune-'1')
+		if !w.table.SetCursorPos(pos) {
+			// no such candidate, e.g. the "3" of "10:30" goes on as typed
+			e.closeCandidateWindow(typed)
+			return false, nil
+		}
+		e.commitCandidate()
+	case w.source.isQueryKey(keyRune):
+		w.query = append(w.query, keyRune)
+		e.updateCandidateWindow()
+	default:
+		e.closeCandidateWindow(typed)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,111 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andodevel/ibus-telex/src/core"
+)
+
+// checkInputMethods parses and validates every input method defined in the
+// given config or definition files (the user's config and the definition
+// directories if none is given) and prints what it finds. It returns the
+// number of errors, so it can be used as an exit code.
+func checkInputMethods(w io.Writer, paths []string) int {
+	var nErrors = 0
+	if len(paths) == 0 {
+		var engineName = strings.ToLower(EngineName)
+		paths = []string{getConfigPath(engineName)}
+		for _, dir := range getInputMethodDirs(engineName) {
+			var imPaths, _ = filepath.Glob(filepath.Join(dir, "*"+core.InputMethodFileExt))
+			sort.Strings(imPaths)
+			paths = append(paths, imPaths...)
+		}
+	}
+	for _, path := range paths {
+		if strings.HasSuffix(path, core.InputMethodFileExt) {
+			nErrors += checkInputMethodFile(w, path)
+			continue
+		}
+		var c, err = loadConfigFile(path)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			nErrors++
+			continue
+		}
+		var names []string
+		for name := range c.InputMethodDefinitions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			var im, err = core.ParseInputMethodWithErrors(c.InputMethodDefinitions, name)
+			nErrors += printInputMethodIssues(w, path, im, err)
+		}
+		if im := core.ParseInputMethod(c.InputMethodDefinitions, c.InputMethod); im.Name == "" {
+			fmt.Fprintf(w, "%s: error: InputMethod %q is not defined\n", path, c.InputMethod)
+			nErrors++
+		}
+	}
+	return nErrors
+}
+
+func checkInputMethodFile(w io.Writer, path string) int {
+	var f, err = core.LoadInputMethodFile(path)
+	if f == nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	var nErrors = printInputMethodIssues(w, path, core.InputMethod{}, err)
+	var im core.InputMethod
+	im, err = f.Parse()
+	return nErrors + printInputMethodIssues(w, path, im, err)
+}
+
+// printInputMethodIssues prints the parse errors and the validation issues of
+// an input method and returns the number of errors.
+func printInputMethodIssues(w io.Writer, path string, im core.InputMethod, err error) int {
+	var nErrors = 0
+	if err != nil {
+		for _, parseErr := range err.(core.ParseErrors) {
+			if parseErr.Source != "" {
+				fmt.Fprintf(w, "error: %s\n", parseErr)
+			} else {
+				fmt.Fprintf(w, "%s: error: %s\n", path, parseErr)
+			}
+			nErrors++
+		}
+	}
+	if im.Name == "" {
+		return nErrors
+	}
+	for _, issue := range core.ValidateInputMethod(im) {
+		fmt.Fprintf(w, "%s: %s: %s\n", path, im.Name, issue)
+		if issue.Severity == core.SeverityError {
+			nErrors++
+		}
+	}
+	return nErrors
+}
@@ -0,0 +1,178 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/andodevel/ibus-telex/src/core"
+)
+
+const maxEmojiCandidates = 45
+
+// emojiEntry is an entry of the emoji dictionary, keyed by its base code
+// points. The output code points are separated by dashes, e.g. "1f1fb-1f1f3".
+// The shipped dictionary is not the EmojiOne one but a hand-picked subset of
+// about 120 common emojis in its JSON format, with Vietnamese keywords added,
+// so that the full EmojiOne file can replace it.
+type emojiEntry struct {
+	Order               int      `json:"order"`
+	Shortname           string   `json:"shortname"`
+	ShortnameAlternates []string `json:"shortname_alternates"`
+	Keywords            []string `json:"keywords"`
+	CodePoints          struct {
+		Output string `json:"output"`
+	} `json:"code_points"`
+}
+
+type emoji struct {
+	text      string
+	shortname string // without colons
+	order     int
+}
+
+// An emojiDict finds emojis by the prefix of their shortcodes and keywords,
+// which may be Vietnamese words without accents, e.g. "cuoi" for 😄.
+type emojiDict struct {
+	trie   *TrieNode // words to the keys of their emojis
+	emojis map[string]emoji
+}
+
+func loadEmojiDict(path string) (*emojiDict, error) {
+	var d = &emojiDict{trie: NewTrie(), emojis: map[string]emoji{}}
+	var data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return d, err
+	}
+	var entries map[string]emojiEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return d, fmt.Errorf("%s: %v", path, err)
+	}
+	for key, entry := range entries {
+		var text, err = parseCodePoints(entry.CodePoints.Output)
+		if err != nil {
+			return d, fmt.Errorf("%s: %s: %v", path, key, err)
+		}
+		var shortname = strings.Trim(entry.Shortname, ":")
+		d.emojis[key] = emoji{text: text, shortname: shortname, order: entry.Order}
+		var words = []string{shortname}
+		for _, alternate := range entry.ShortnameAlternates {
+			words = append(words, strings.Trim(alternate, ":"))
+		}
+		for _, word := range append(words, entry.Keywords...) {
+			if word != "" {
+				InsertTrie(d.trie, strings.ToLower(word), key)
+			}
+		}
+	}
+	return d, nil
+}
+
+func parseCodePoints(str string) (string, error) {
+	var runes []rune
+	for _, hex := range strings.Split(str, "-") {
+		var code, err = strconv.ParseUint(hex, 16, 32)
+		if err != nil || code > unicode.MaxRune {
+			return "", fmt.Errorf("invalid code point %q", hex)
+		}
+		runes = append(runes, rune(code))
+	}
+	return string(runes), nil
+}
+
+// findCandidates returns the emojis of the words starting with query: those
+// whose shortcode is query first, then the other exact matches, then the
+// shortest words, in the order of the dictionary.
+func (d *emojiDict) findCandidates(query string) []candidate {
+	query = strings.ToLower(query)
+	type match struct {
+		emoji
+		rank int
+	}
+	var best = map[string]match{}
+	for word, keys := range FindPrefix(d.trie, query) {
+		for _, key := range strings.Split(keys, ":") {
+			var e = d.emojis[key]
+			var rank = 2*(len(word)-len(query)) + 1
+			if word == e.shortname && word == query {
+				rank = 0
+			}
+			if m, found := best[key]; !found || rank < m.rank {
+				best[key] = match{e, rank}
+			}
+		}
+	}
+	var matches []match
+	for _, m := range best {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank < matches[j].rank
+		}
+		return matches[i].order < matches[j].order
+	})
+	var candidates []candidate
+	for i, m := range matches {
+		if i == maxEmojiCandidates {
+			break
+		}
+		candidates = append(candidates, candidate{text: m.text, label: m.text + " " + m.shortname})
+	}
+	return candidates
+}
+
+func (d *emojiDict) isQueryKey(key rune) bool {
+	return key >= 'a' && key <= 'z' || key >= 'A' && key <= 'Z' || key == '_' || key == '+' || key == '-'
+}
+
+func (d *emojiDict) selectsWithDigits() bool {
+	return true
+}
+
+// openEmojiWindow opens the emoji candidates when ':' starts a word, loading
+// the dictionary the first time. It reports whether it did.
+func (e *IBusTelex) openEmojiWindow(keyVal, state uint32) bool {
+	if e.config.JupiterFlags&JemojiEnabled == 0 || keyVal != IBusOpenEmojiTable || !e.isValidState(state) ||
+		len(keyPressChan) > 0 || e.getProcessedString(core.EnglishMode) != "" {
+		return false
+	}
+	if e.emojiDict == nil {
+		var err error
+		e.emojiDict, err = loadEmojiDict(getEngineSubFile(DictEmoji))
+		if err != nil {
+			log.Println(err)
+			showNotification("Emoji dictionary errors", err.Error())
+		}
+		log.Printf("Loaded %d emojis", len(e.emojiDict.emojis))
+	}
+	if len(e.emojiDict.emojis) == 0 {
+		return false
+	}
+	e.openCandidateWindow(e.emojiDict, ":", ":")
+	return true
+}
@@ -38,11 +38,28 @@ type IBusTelex struct {
 	preeditor              core.IEngine
 	engineName             string
 	config                 *Config
+	inputMethodFiles       map[string]*core.InputMethodFile
+	inputMethodErr         string
+	lexicon                *core.Lexicon
+	englishWords           core.WordList
+	restoreOverrides       restoreOverrides
+	lastWord               lastWord
+	macroTable             *MacroTable
+	macroModTime           int64
+	snippetTable           *MacroTable
+	snippetModTime         int64
+	emojiDict              *emojiDict
+	symbolTable            *symbolTable
+	candidateWindow        *candidateWindow
+	personalLexicon        *personalLexicon
+	completionWindow       *candidateWindow
 	propList               *ibus.PropList
 	wmClasses              string
 	isInputModeLTOpened    bool
 	inputModeLookupTable   *ibus.LookupTable
 	capabilities           uint32
+	contentPurpose         uint32
+	contentHints           uint32
 	keyPressDelay          int
 	nFakeBackSpace         int
 	isFirstTimeSendingBS   bool
@@ -66,9 +83,26 @@ Return:
 This function gets called whenever a key is pressed.
 */
 func (e *IBusTelex) ProcessKeyEvent(keyVal uint32, keyCode uint32, state uint32) (bool, *dbus.Error) {
+	if state&IBusReleaseMask == 0 && e.isClipboardHotkey(keyVal, state) {
+		e.resetBuffer()
+		go e.convertClipboard()
+		return true, nil
+	}
+	if state&IBusReleaseMask == 0 && e.candidateWindow == nil && e.isSymbolPickerHotkey(keyVal, state) {
+		e.resetBuffer()
+		e.openSymbolPicker()
+		return true, nil
+	}
 	if e.isIgnoredKey(keyVal, state) {
 		return false, nil
 	}
+	e.trackLastWord(keyVal)
+	if e.candidateWindow != nil {
+		return e.cwProcessKeyEvent(keyVal, keyCode, state)
+	}
+	if e.openEmojiWindow(keyVal, state) {
+		return true, nil
+	}
 	log.Printf("ProcessKeyEvent >  %c | keyCode 0x%04x keyVal 0x%04x | %d\n", rune(keyVal), keyCode, keyVal, len(keyPressChan))
 	if e.isInputModeLTOpened {
 		return e.ltProcessKeyEvent(keyVal, keyCode, state)
@@ -85,9 +119,19 @@ func (e *IBusTelex) FocusIn() *dbus.Error {
 	e.wmClasses = x11.GetFocusWindowClass()
 	fmt.Printf("WM_CLASS=(%s)\n", e.wmClasses)
 
+	if oldWmClasses != e.wmClasses {
+		// the charset menu shows the mapping of the focused application
+		e.propList = GetPropListByConfig(e.config, e.inputMethodFiles, e.wmClasses, e.restoreOverrides)
+	}
 	e.RegisterProperties(e.propList)
 	e.RequireSurroundingText()
+	e.loadMacroTable()
+	e.loadSnippets()
 	if oldWmClasses != e.wmClasses {
+		if e.candidateWindow != nil {
+			e.closeCandidateWindow("")
+		}
+		e.hideCompletions()
 		e.resetBuffer()
 		e.resetFakeBackspace()
 	}
@@ -97,6 +141,11 @@ func (e *IBusTelex) FocusIn() *dbus.Error {
 func (e *IBusTelex) FocusOut() *dbus.Error {
 	log.Print("FocusOut.")
 	//e.wmClasses = ""
+	e.personalLexicon.endText()
+	e.savePersonalLexicon()
+	// the next field sets its own content type
+	e.contentPurpose = 0
+	e.contentHints = 0
 	return nil
 }
 
@@ -139,12 +188,13 @@ func (e *IBusTelex) SetSurroundingText(text dbus.Variant, cursorPos uint32, anch
 		if len(s) < int(cursorPos) {
 			return nil
 		}
-		var cs = s[:cursorPos]
+		// the text is read back in the output charset
+		var cs = []rune(core.Decode(e.getOutputCharset(), string(s[:cursorPos])))
 		fmt.Println("Surrounding Text: ", string(cs))
 		e.preeditor.Reset()
 		for i := len(cs) - 1; i >= 0; i-- {
 			// workaround for spell checking
-			if core.IsPunctuationMark(cs[i]) && e.preeditor.CanProcessKey(cs[i]) {
+			if core.IsWordBreakSymbol(cs[i]) && e.preeditor.CanProcessKey(cs[i]) {
 				cs[i] = ' '
 			}
 			e.preeditor.ProcessKey(cs[i], core.EnglishMode|core.InReverseOrder)
@@ -156,6 +206,8 @@ func (e *IBusTelex) SetSurroundingText(text dbus.Variant, cursorPos uint32, anch
 func (e *IBusTelex) PageUp() *dbus.Error {
 	if e.isInputModeLTOpened && e.inputModeLookupTable.PageUp() {
 		e.updateInputModeLT()
+	} else if w := e.getShownWindow(); w != nil && w.table.PageUp() {
+		e.showCandidateTable(w)
 	}
 	return nil
 }
@@ -163,6 +215,8 @@ func (e *IBusTelex) PageUp() *dbus.Error {
 func (e *IBusTelex) PageDown() *dbus.Error {
 	if e.isInputModeLTOpened && e.inputModeLookupTable.PageDown() {
 		e.updateInputModeLT()
+	} else if w := e.getShownWindow(); w != nil && w.table.PageDown() {
+		e.showCandidateTable(w)
 	}
 	return nil
 }
@@ -170,6 +224,8 @@ func (e *IBusTelex) PageDown() *dbus.Error {
 func (e *IBusTelex) CursorUp() *dbus.Error {
 	if e.isInputModeLTOpened && e.inputModeLookupTable.CursorUp() {
 		e.updateInputModeLT()
+	} else if w := e.getShownWindow(); w != nil && w.table.CursorUp() {
+		e.showCandidateTable(w)
 	}
 	return nil
 }
@@ -177,6 +233,8 @@ func (e *IBusTelex) CursorUp() *dbus.Error {
 func (e *IBusTelex) CursorDown() *dbus.Error {
 	if e.isInputModeLTOpened && e.inputModeLookupTable.CursorDown() {
 		e.updateInputModeLT()
+	} else if w := e.getShownWindow(); w != nil && int(w.table.CursorPos)+1 < len(w.candidates) && w.table.CursorDown() {
+		e.showCandidateTable(w)
 	}
 	return nil
 }
@@ -185,6 +243,13 @@ func (e *IBusTelex) CandidateClicked(index uint32, button uint32, state uint32)
 	if e.isInputModeLTOpened && e.inputModeLookupTable.SetCursorPos(index) {
 		e.commitInputModeCandidate()
 		e.closeInputModeCandidates()
+	} else if w := e.getShownWindow(); w != nil && w.table.SetCursorPos(w.table.CursorPos/w.table.PageSize*w.table.PageSize+index) {
+		// the index is in the current page
+		if w == e.completionWindow {
+			e.commitCompletion()
+		} else {
+			e.commitCandidate()
+		}
 	}
 	return nil
 }
@@ -199,6 +264,8 @@ func (e *IBusTelex) SetCursorLocation(x int32, y int32, w int32, h int32) *dbus.
 }
 
 func (e *IBusTelex) SetContentType(purpose uint32, hints uint32) *dbus.Error {
+	e.contentPurpose = purpose
+	e.contentHints = hints
 	return nil
 }
 
@@ -212,6 +279,28 @@ func (e *IBusTelex) PropertyActivate(propName string, propState uint32) *dbus.Er
 		exec.Command("xdg-open", getConfigPath(e.engineName)).Start()
 		return nil
 	}
+	if propName == PropKeyMacroEdit {
+		createMacroFile(e.engineName)
+		exec.Command("xdg-open", getMacroPath(e.engineName)).Start()
+		return nil
+	}
+	if propName == PropKeySnippetsEdit {
+		createSnippetsDir(e.engineName)
+		exec.Command("xdg-open", getSnippetsDir(e.engineName)).Start()
+		return nil
+	}
+	if propName == PropKeyClipboardConvert {
+		go e.convertClipboard()
+		return nil
+	}
+	if keys, found := getValueFromPropKey(PropKeyRestoreOverride, propName); found {
+		delete(e.restoreOverrides, keys)
+		e.saveRestoreOverrides()
+	}
+	if propName == PropKeyRestoreOverridesClear {
+		e.restoreOverrides = restoreOverrides{}
+		e.saveRestoreOverrides()
+	}
 
 	if propName == PropKeyStdToneStyle {
 		if propState == ibus.PROP_STATE_CHECKED {
@@ -220,6 +309,47 @@ func (e *IBusTelex) PropertyActivate(propName string, propState uint32) *dbus.Er
 			e.config.Flags &= ^core.EstdToneStyle
 		}
 	}
+	if propName == PropKeyMacroEnabled {
+		if propState == ibus.PROP_STATE_CHECKED {
+			e.config.JupiterFlags |= JmacroEnabled
+		} else {
+			e.config.JupiterFlags &= ^JmacroEnabled
+		}
+	}
+	if propName == PropKeyEmojiEnabled {
+		if propState == ibus.PROP_STATE_CHECKED {
+			e.config.JupiterFlags |= JemojiEnabled
+		} else {
+			e.config.JupiterFlags &= ^JemojiEnabled
+		}
+	}
+	if propName == PropKeyMacroAutoCapitalize {
+		if propState == ibus.PROP_STATE_CHECKED {
+			e.config.JupiterFlags |= JmacroAutoCapitalize
+		} else {
+			e.config.JupiterFlags &= ^JmacroAutoCapitalize
+		}
+	}
+	if propName == PropKeyAutocomplete {
+		if propState == ibus.PROP_STATE_CHECKED {
+			e.config.AutocompleteWhiteList = addToWhiteList(e.config.AutocompleteWhiteList, e.wmClasses)
+		} else {
+			e.config.AutocompleteWhiteList = removeFromWhiteList(e.config.AutocompleteWhiteList, e.wmClasses)
+		}
+	}
+	if propName == PropKeyLearningExcepted {
+		if propState == ibus.PROP_STATE_CHECKED {
+			e.config.LearningExceptedList = addToWhiteList(e.config.LearningExceptedList, e.wmClasses)
+		} else {
+			e.config.LearningExceptedList = removeFromWhiteList(e.config.LearningExceptedList, e.wmClasses)
+		}
+	}
+	if mode, found := getValueFromPropKey(PropKeySpellCheck, propName); found && propState == ibus.PROP_STATE_CHECKED {
+		e.config.Flags = setSpellCheckMode(e.config.Flags, mode)
+		if mode == SpellCheckDictionary && e.lexicon.Len() == 0 {
+			showNotification("Spell checking", "No lexicon found, spelling is checked with the rules only")
+		}
+	}
 	if propName == PropKeyMouseCapturing {
 		if propState == ibus.PROP_STATE_CHECKED {
 			e.config.IBflags |= IBmouseCapturing
@@ -230,20 +360,36 @@ func (e *IBusTelex) PropertyActivate(propName string, propState uint32) *dbus.Er
 		}
 	}
 
-	var charset, foundCs = getCharsetFromPropKey(propName)
-	if foundCs && isValidCharset(charset) && propState == ibus.PROP_STATE_CHECKED {
+	if charset, found := getAppCharsetFromPropKey(propName); found {
+		if propState == ibus.PROP_STATE_CHECKED && isValidCharset(charset) {
+			if e.config.OutputCharsetMapping == nil {
+				e.config.OutputCharsetMapping = map[string]string{}
+			}
+			e.config.OutputCharsetMapping[e.wmClasses] = charset
+		} else if propState == ibus.PROP_STATE_CHECKED {
+			delete(e.config.OutputCharsetMapping, e.wmClasses)
+		}
+	} else if charset, foundCs := getCharsetFromPropKey(propName); foundCs && isValidCharset(charset) && propState == ibus.PROP_STATE_CHECKED {
 		e.config.OutputCharset = charset
 	}
-	if _, found := e.config.InputMethodDefinitions[propName]; found && propState == ibus.PROP_STATE_CHECKED {
+	if action, found := getValueFromPropKey(PropKeyClipboardAction, propName); found && propState == ibus.PROP_STATE_CHECKED {
+		e.config.ClipboardAction = action
+	}
+	if charset, found := getValueFromPropKey(PropKeyClipboardFrom, propName); found && propState == ibus.PROP_STATE_CHECKED {
+		e.config.ClipboardFromCharset = charset
+	}
+	if charset, found := getValueFromPropKey(PropKeyClipboardTo, propName); found && isValidCharset(charset) && propState == ibus.PROP_STATE_CHECKED {
+		e.config.ClipboardToCharset = charset
+	}
+	if _, found := e.getInputMethodDefinitions()[propName]; found && propState == ibus.PROP_STATE_CHECKED {
 		e.config.InputMethod = propName
 	}
 	if propName != "-" {
 		saveConfig(e.config, e.engineName)
 	}
-	e.propList = GetPropListByConfig(e.config)
+	e.propList = GetPropListByConfig(e.config, e.inputMethodFiles, e.wmClasses, e.restoreOverrides)
 
-	var inputMethod = core.ParseInputMethod(e.config.InputMethodDefinitions, e.config.InputMethod)
-	e.preeditor = core.NewEngine(inputMethod, e.config.Flags)
+	e.loadInputMethod()
 	e.RegisterProperties(e.propList)
 	return nil
 }
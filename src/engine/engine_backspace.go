@@ -22,6 +22,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/andodevel/ibus-telex/src/core"
@@ -69,7 +70,7 @@ func (e *IBusTelex) bsProcessKeyEvent(keyVal uint32, keyCode uint32, state uint3
 	}
 	if len(keyPressChan) == 0 && e.getRawKeyLen() == 0 && !inKeyList(e.preeditor.GetInputMethod().AppendingKeys, keyRune) {
 		e.updateLastKeyWithShift(keyVal, state)
-		if e.preeditor.CanProcessKey(keyRune) && e.isValidState(state) {
+		if e.preeditor.CanProcessKey(keyRune) && !e.preeditor.IsWordBreakSymbol(keyRune) && e.isValidState(state) {
 			e.isFirstTimeSendingBS = true
 			if state&IBusLockMask != 0 {
 				keyRune = e.toUpper(keyRune)
@@ -97,18 +98,15 @@ func (e *IBusTelex) keyPressHandler(keyVal, keyCode, state uint32) {
 		return
 	}
 	var keyRune = rune(keyVal)
+	var isWordBreak = e.preeditor.IsWordBreakSymbol(keyRune)
 	oldText := e.getPreeditString()
 	if keyVal == IBusBackSpace {
 		if e.getRawKeyLen() > 0 {
-			if e.config.IBflags&IBautoNonVnRestore == 0 {
-				e.preeditor.RemoveLastChar(false)
-				e.ForwardKeyEvent(keyVal, keyCode, state)
-				return
-			}
-			e.preeditor.RemoveLastChar(true)
+			e.preeditor.RemoveLastChar(e.config.IBflags&IBautoNonVnRestore != 0)
 			var newText = e.getPreeditString()
-			var offset = e.getPreeditOffset([]rune(newText), []rune(oldText))
-			if oldText != "" && offset != len([]rune(newText)) {
+			// a single backspace is not enough when the last letter was
+			// encoded into several characters, e.g. VNI Windows
+			if !e.isLastCharRemoval(newText, oldText) {
 				e.updatePreviousText(newText, oldText)
 				return
 			}
@@ -123,7 +121,7 @@ func (e *IBusTelex) keyPressHandler(keyVal, keyCode, state uint32) {
 		return
 	}
 
-	if e.preeditor.CanProcessKey(keyRune) {
+	if e.preeditor.CanProcessKey(keyRune) && !isWordBreak {
 		if state&IBusLockMask != 0 {
 			keyRune = e.toUpper(keyRune)
 		}
@@ -142,15 +140,32 @@ func (e *IBusTelex) keyPressHandler(keyVal, keyCode, state uint32) {
 			e.updatePreviousText(e.getPreeditString(), oldText)
 		}
 		return
-	} else if core.IsWordBreakSymbol(keyRune) {
-		if core.HasAnyVietnameseRune(oldText) && e.mustFallbackToEnglish() {
-			e.preeditor.RestoreLastWord()
-			newText := e.preeditor.GetProcessedString(core.EnglishMode) + string(keyRune)
-			e.updatePreviousText(newText, oldText)
-			e.preeditor.ProcessKey(keyRune, core.EnglishMode)
+	} else if isWordBreak {
+		if expanded, afterCursor, found := e.expandSnippet(oldText, keyRune); found {
+			e.personalLexicon.endText()
+			e.updatePreviousText(expanded, oldText)
+			e.preeditor.Reset()
+			e.moveCursorLeft(afterCursor)
+			return
+		}
+		if expanded, found := e.expandMacro(oldText); found {
+			e.personalLexicon.endText()
+			e.updatePreviousText(expanded+string(keyRune), oldText)
+			// the composition no longer matches the text of the application
+			e.preeditor.Reset()
+			return
+		}
+		var newText = e.getComposedString(oldText)
+		e.learnText(newText + string(keyRune))
+		if newText != oldText {
+			if newText == e.getProcessedString(core.EnglishMode) {
+				e.preeditor.RestoreLastWord()
+			}
+			e.updatePreviousText(newText+string(keyRune), oldText)
+			e.appendWordBreak(keyRune)
 			return
 		}
-		e.preeditor.ProcessKey(keyRune, core.EnglishMode)
+		e.appendWordBreak(keyRune)
 		e.SendText([]rune{keyRune})
 		return
 	}
@@ -158,30 +173,60 @@ func (e *IBusTelex) keyPressHandler(keyVal, keyCode, state uint32) {
 	e.ForwardKeyEvent(keyVal, keyCode, state)
 }
 
-func (e *IBusTelex) getPreeditOffset(newRunes, oldRunes []rune) int {
-	var minLen = len(oldRunes)
-	if len(newRunes) < minLen {
-		minLen = len(newRunes)
+// appendWordBreak keeps a word break in the composition, except for effect keys
+// (e.g. VNI digits) which would otherwise be read back as part of the next word.
+func (e *IBusTelex) appendWordBreak(keyRune rune) {
+	if e.preeditor.CanProcessKey(keyRune) {
+		e.preeditor.Reset()
+		return
+	}
+	e.preeditor.ProcessKey(keyRune, core.EnglishMode)
+}
+
+func (e *IBusTelex) getPreeditOffset(newGraphemes, oldGraphemes []string) int {
+	var minLen = len(oldGraphemes)
+	if len(newGraphemes) < minLen {
+		minLen = len(newGraphemes)
 	}
 	for i := 0; i < minLen; i++ {
-		if oldRunes[i] != newRunes[i] {
+		if oldGraphemes[i] != newGraphemes[i] {
 			return i
 		}
 	}
 	return minLen
 }
 
+// splitEncodedText encodes text and splits it into the characters that one
+// backspace deletes in the application.
+func (e *IBusTelex) splitEncodedText(text string) []string {
+	return core.SplitGraphemes(e.getOutputCharset(), e.encodeText(text))
+}
+
+// isLastCharRemoval reports whether the committed text only lost its last
+// character, so that the backspace key can simply be forwarded.
+func (e *IBusTelex) isLastCharRemoval(newText, oldText string) bool {
+	var oldGraphemes = e.splitEncodedText(oldText)
+	var newGraphemes = e.splitEncodedText(newText)
+	return len(oldGraphemes) == 0 ||
+		len(newGraphemes) == len(oldGraphemes)-1 && e.getPreeditOffset(newGraphemes, oldGraphemes) == len(newGraphemes)
+}
+
+// updatePreviousText replaces oldText by newText in the application. Both are
+// compared once encoded, since the output charset may use more than one
+// character per letter. Backspaces are counted in graphemes: applications
+// delete a decomposed letter (Unicode tổ hợp) and its combining marks at once,
+// while the tone byte of CP1258 is a character of its own.
 func (e *IBusTelex) updatePreviousText(newText, oldText string) {
-	var oldRunes = []rune(oldText)
-	var newRunes = []rune(newText)
+	var oldGraphemes = e.splitEncodedText(oldText)
+	var newGraphemes = e.splitEncodedText(newText)
 	var nBackSpace = 0
-	var offset = e.getPreeditOffset(newRunes, oldRunes)
-	if offset < len(oldRunes) {
-		nBackSpace += len(oldRunes) - offset
+	var offset = e.getPreeditOffset(newGraphemes, oldGraphemes)
+	if offset < len(oldGraphemes) {
+		nBackSpace += len(oldGraphemes) - offset
 	}
 
 	// workaround for chrome and firefox's address bar
-	if e.isFirstTimeSendingBS && offset < len(newRunes) && offset < len(oldRunes) && e.inBrowserList() &&
+	if e.isFirstTimeSendingBS && offset < len(newGraphemes) && offset < len(oldGraphemes) && e.inBrowserList() &&
 		!e.checkInputMode(shiftLeftForwardingIM) {
 		fmt.Println("Append a deadkey")
 		e.SendText([]rune(" "))
@@ -191,17 +236,17 @@ func (e *IBusTelex) updatePreviousText(newText, oldText string) {
 	}
 
 	log.Printf("Updating Previous Text %s ---> %s\n", oldText, newText)
-	e.sendBackspaceAndNewRunes(nBackSpace, newRunes[offset:])
+	e.sendBackspaceAndNewRunes(nBackSpace, []rune(strings.Join(newGraphemes[offset:], "")))
 }
 
-func (e *IBusTelex) sendBackspaceAndNewRunes(nBackSpace int, newRunes []rune) {
+func (e *IBusTelex) sendBackspaceAndNewRunes(nBackSpace int, encodedRunes []rune) {
 	if nBackSpace > 0 {
 		if e.checkInputMode(xTestFakeKeyEventIM) {
 			e.nFakeBackSpace = nBackSpace
 		}
 		e.SendBackSpace(nBackSpace)
 	}
-	e.SendText(newRunes)
+	e.sendEncodedText(encodedRunes)
 }
 
 func (e *IBusTelex) SendBackSpace(n int) {
@@ -262,6 +307,22 @@ func (e *IBusTelex) resetFakeBackspace() {
 }
 
 func (e *IBusTelex) SendText(rs []rune) {
+	e.sendEncodedText([]rune(e.encodeText(string(rs))))
+}
+
+// sendEncodedText types each new line with the Return key, which applications
+// understand better than a line feed, e.g. in the templates of snippets.
+func (e *IBusTelex) sendEncodedText(rs []rune) {
+	for i, line := range strings.Split(string(rs), "\n") {
+		if i > 0 {
+			e.ForwardKeyEvent(IBusReturn, XkReturn-8, 0)
+			e.ForwardKeyEvent(IBusReturn, XkReturn-8, IBusReleaseMask)
+		}
+		e.sendEncodedLine([]rune(line))
+	}
+}
+
+func (e *IBusTelex) sendEncodedLine(rs []rune) {
 	if len(rs) == 0 {
 		return
 	}
@@ -269,7 +330,10 @@ func (e *IBusTelex) SendText(rs []rune) {
 		log.Println("Forward as commit", string(rs))
 		for _, chr := range rs {
 			var keyVal = vnSymMapping[chr]
-			if keyVal == 0 {
+			if keyVal == 0 && chr > 0xff {
+				// e.g. combining marks, see the Unicode keysyms of X11
+				keyVal = 0x1000000 + uint32(chr)
+			} else if keyVal == 0 {
 				keyVal = uint32(chr)
 			}
 			e.ForwardKeyEvent(keyVal, 0, 0)
@@ -278,5 +342,5 @@ func (e *IBusTelex) SendText(rs []rune) {
 		time.Sleep(time.Duration(len(rs)) * 5 * time.Millisecond)
 		return
 	}
-	e.commitText(string(rs))
+	e.commitEncodedText(string(rs))
 }
@@ -0,0 +1,160 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/andodevel/ibus-telex/src/core"
+	"github.com/andodevel/ibus-telex/src/x11"
+)
+
+// The clipboard actions, as saved in the config.
+const (
+	ClipboardConvertCharset = "charset"
+	ClipboardRemoveAccents  = "no_accents"
+	ClipboardUpperCase      = "upper_case"
+	ClipboardLowerCase      = "lower_case"
+	ClipboardCapitalize     = "capitalize"
+)
+
+var clipboardActions = []string{
+	ClipboardConvertCharset,
+	ClipboardRemoveAccents,
+	ClipboardUpperCase,
+	ClipboardLowerCase,
+	ClipboardCapitalize,
+}
+
+var clipboardActionLabels = map[string]string{
+	ClipboardConvertCharset: "Chuyển bảng mã",
+	ClipboardRemoveAccents:  "Bỏ dấu",
+	ClipboardUpperCase:      "CHỮ HOA",
+	ClipboardLowerCase:      "chữ thường",
+	ClipboardCapitalize:     "Viết Hoa Đầu Từ",
+}
+
+const clipboardTimeoutMs = 500
+
+// convertClipboard applies the configured action to the clipboard text and
+// puts the result back on the clipboard, like the Unikey toolkit does.
+func (e *IBusTelex) convertClipboard() {
+	var text = x11.GetClipboard(clipboardTimeoutMs)
+	if text == "" {
+		showNotification("Clipboard", "The clipboard has no text")
+		return
+	}
+	var converted, summary = convertClipboardText(e.config, text)
+	log.Printf("Clipboard: %s", summary)
+	if converted == text {
+		showNotification("Clipboard", summary+"\nNothing to convert")
+		return
+	}
+	x11.Copy(converted)
+	showNotification("Clipboard", summary)
+}
+
+// convertClipboardText returns the converted text and what was done to it.
+func convertClipboardText(c *Config, text string) (string, string) {
+	switch c.ClipboardAction {
+	case ClipboardRemoveAccents:
+		return core.RemoveAccents(text), clipboardActionLabels[c.ClipboardAction]
+	case ClipboardUpperCase:
+		return strings.ToUpper(text), clipboardActionLabels[c.ClipboardAction]
+	case ClipboardLowerCase:
+		return strings.ToLower(text), clipboardActionLabels[c.ClipboardAction]
+	case ClipboardCapitalize:
+		return capitalizeWords(text), clipboardActionLabels[c.ClipboardAction]
+	}
+	var from = c.ClipboardFromCharset
+	if !isValidCharset(from) {
+		var guess = core.DetectTextCharset(text)[0]
+		if guess.Confidence == 0 {
+			return text, "No Vietnamese letters"
+		}
+		from = guess.Charset
+	}
+	var to = c.ClipboardToCharset
+	if !isValidCharset(to) {
+		to = core.UNICODE
+	}
+	return core.Encode(to, core.Decode(from, text)), fmt.Sprintf("%s → %s", from, to)
+}
+
+func capitalizeWords(text string) string {
+	var runes = []rune(text)
+	for i, chr := range runes {
+		if i == 0 || !unicode.IsLetter(runes[i-1]) {
+			runes[i] = unicode.ToUpper(chr)
+		} else {
+			runes[i] = unicode.ToLower(chr)
+		}
+	}
+	return string(runes)
+}
+
+// isClipboardHotkey reports whether the key event matches the configured
+// hotkey, e.g. "Control+Shift+F9".
+func (e *IBusTelex) isClipboardHotkey(keyVal, state uint32) bool {
+	return isHotkey(e.config.ClipboardHotkey, keyVal, state)
+}
+
+// isHotkey reports whether a key event is the given hotkey, see parseHotkey.
+func isHotkey(hotkey string, keyVal, state uint32) bool {
+	var hotkeyVal, hotkeyState, ok = parseHotkey(hotkey)
+	if !ok {
+		return false
+	}
+	var mask uint32 = IBusShiftMask | IBusControlMask | IBusMod1Mask | IBusSuperMask
+	return state&mask == hotkeyState && unicode.ToLower(rune(keyVal)) == rune(hotkeyVal)
+}
+
+var hotkeyModifiers = map[string]uint32{
+	"control": IBusControlMask,
+	"ctrl":    IBusControlMask,
+	"shift":   IBusShiftMask,
+	"alt":     IBusMod1Mask,
+	"super":   IBusSuperMask,
+}
+
+// parseHotkey parses modifiers and a key joined by "+", the key being F1 to F12
+// or a single character.
+func parseHotkey(hotkey string) (uint32, uint32, bool) {
+	var parts = strings.Split(hotkey, "+")
+	var state uint32
+	for _, modifier := range parts[:len(parts)-1] {
+		var mask, found = hotkeyModifiers[strings.ToLower(strings.TrimSpace(modifier))]
+		if !found {
+			return 0, 0, false
+		}
+		state |= mask
+	}
+	var key = strings.TrimSpace(parts[len(parts)-1])
+	if n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(key), "F")); err == nil && len(key) > 1 && n >= 1 && n <= 12 {
+		return IBusF1 + uint32(n-1), state, true
+	}
+	if keyRunes := []rune(strings.ToLower(key)); len(keyRunes) == 1 {
+		return uint32(keyRunes[0]), state, true
+	}
+	return 0, 0, false
+}
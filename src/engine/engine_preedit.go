@@ -30,15 +30,21 @@ import (
 )
 
 func (e *IBusTelex) preeditProcessKeyEvent(keyVal uint32, keyCode uint32, state uint32) (bool, *dbus.Error) {
+	if e.completionWindow != nil && e.completionProcessKeyEvent(keyVal, state) {
+		return true, nil
+	}
 	var rawKeyLen = e.getRawKeyLen()
 	var keyRune = rune(keyVal)
 	var oldText = e.getPreeditString()
 	defer e.updateLastKeyWithShift(keyVal, state)
 
+	var isWordBreak = e.preeditor.IsWordBreakSymbol(keyRune)
+
 	// workaround for chrome's address bar and Google SpreadSheets
 	if !e.isValidState(state) || !e.canProcessKey(keyVal) ||
-		(rawKeyLen == 0 && !e.preeditor.CanProcessKey(keyRune)) {
+		(rawKeyLen == 0 && (!e.preeditor.CanProcessKey(keyRune) || isWordBreak)) {
 		if rawKeyLen > 0 {
+			e.hideCompletions()
 			e.HidePreeditText()
 			e.commitText(e.getPreeditString())
 			e.preeditor.Reset()
@@ -59,7 +65,7 @@ func (e *IBusTelex) preeditProcessKeyEvent(keyVal uint32, keyCode uint32, state
 		return false, nil
 	}
 
-	if e.preeditor.CanProcessKey(keyRune) {
+	if e.preeditor.CanProcessKey(keyRune) && !isWordBreak {
 		if state&IBusLockMask != 0 {
 			keyRune = e.toUpper(keyRune)
 		}
@@ -76,7 +82,18 @@ func (e *IBusTelex) preeditProcessKeyEvent(keyVal uint32, keyCode uint32, state
 			e.updatePreedit(e.getPreeditString())
 		}
 		return true, nil
-	} else if core.IsWordBreakSymbol(keyRune) {
+	} else if isWordBreak {
+		if expanded, afterCursor, found := e.expandSnippet(oldText, keyRune); found {
+			e.resetPreedit()
+			e.commitExpansion(expanded)
+			e.moveCursorLeft(afterCursor)
+			return true, nil
+		}
+		if expanded, found := e.expandMacro(oldText); found {
+			e.resetPreedit()
+			e.commitExpansion(expanded + string(keyRune))
+			return true, nil
+		}
 		e.commitPreedit(e.getComposedString(oldText) + string(keyRune))
 		return true, nil
 	}
@@ -88,6 +105,7 @@ func (e *IBusTelex) updatePreedit(processedStr string) {
 	var encodedStr = e.encodeText(processedStr)
 	var preeditLen = uint32(len([]rune(encodedStr)))
 	if preeditLen == 0 {
+		e.hideCompletions()
 		e.HidePreeditText()
 		e.CommitText(ibus.NewText(""))
 		return
@@ -95,6 +113,7 @@ func (e *IBusTelex) updatePreedit(processedStr string) {
 	var ibusText = ibus.NewText(encodedStr)
 	ibusText.AppendAttr(ibus.IBUS_ATTR_TYPE_NONE, ibus.IBUS_ATTR_UNDERLINE_SINGLE, 0, preeditLen)
 	e.UpdatePreeditTextWithMode(ibusText, preeditLen, true, ibus.IBUS_ENGINE_PREEDIT_COMMIT)
+	e.updateCompletions(processedStr)
 
 	if e.config.IBflags&IBmouseCapturing != 0 {
 		x11.MouseCaptureUnlock()
@@ -129,6 +148,9 @@ func (e *IBusTelex) shouldFallbackToEnglish(checkVnRune bool) bool {
 	if len(vnRunes) == 0 {
 		return false
 	}
+	if english, found := e.getRestoreOverride(); found {
+		return english
+	}
 	// we want to allow dd even in non-vn sequence, because dd is used a lot in abbreviation
 	if e.config.IBflags&IBddFreeStyle != 0 && (vnRunes[len(vnRunes)-1] == 'd' || strings.ContainsRune(vnSeq, 'đ')) {
 		return false
@@ -139,6 +161,9 @@ func (e *IBusTelex) shouldFallbackToEnglish(checkVnRune bool) bool {
 	return !e.preeditor.IsValid(false)
 }
 
+// mustFallbackToEnglish is checked when a word ends. Besides the words that
+// are not Vietnamese, known English words are restored as typed, e.g. "keep"
+// rather than "kêp". The words the user corrected come first.
 func (e *IBusTelex) mustFallbackToEnglish() bool {
 	if e.config.IBflags&IBautoNonVnRestore == 0 {
 		return false
@@ -148,18 +173,40 @@ func (e *IBusTelex) mustFallbackToEnglish() bool {
 	if len(vnRunes) == 0 {
 		return false
 	}
+	if english, found := e.getRestoreOverride(); found {
+		return english
+	}
+	if e.englishWords.Has(e.getProcessedString(core.EnglishMode)) {
+		return true
+	}
 	return !e.preeditor.IsValid(true)
 }
 
+// getComposedString returns the text to commit for the word that ends, oldText
+// being how it was shown while typing.
 func (e *IBusTelex) getComposedString(oldText string) string {
+	if e.learnRetypedWord() {
+		oldText = e.getPreeditString()
+	}
+	var text = oldText
 	if core.HasAnyVietnameseRune(oldText) && e.mustFallbackToEnglish() {
-		return e.getProcessedString(core.EnglishMode)
+		text = e.getProcessedString(core.EnglishMode)
 	}
-	return oldText
+	e.followLastWord(text)
+	return text
 }
 
 func (e *IBusTelex) encodeText(text string) string {
-	return core.Encode(e.config.OutputCharset, text)
+	return core.Encode(e.getOutputCharset(), text)
+}
+
+// getOutputCharset returns the charset mapped to the focused application, if
+// any, or the default one.
+func (e *IBusTelex) getOutputCharset() string {
+	if charset, ok := e.config.OutputCharsetMapping[e.wmClasses]; ok && isValidCharset(charset) {
+		return charset
+	}
+	return e.config.OutputCharset
 }
 
 func (e *IBusTelex) getProcessedString(mode core.Mode) string {
@@ -174,11 +221,13 @@ func (e *IBusTelex) getPreeditString() string {
 }
 
 func (e *IBusTelex) resetPreedit() {
+	e.hideCompletions()
 	e.HidePreeditText()
 	e.preeditor.Reset()
 }
 
 func (e *IBusTelex) commitPreedit(s string) {
+	e.hideCompletions()
 	e.commitText(s)
 	e.HidePreeditText()
 	e.preeditor.Reset()
@@ -188,8 +237,24 @@ func (e *IBusTelex) commitText(str string) {
 	if str == "" {
 		return
 	}
+	e.learnText(str)
+	e.commitEncodedText(e.encodeText(str))
+}
+
+// commitExpansion commits the text of a snippet, a macro, an emoji or a
+// completion. It is not learned as it was not typed, e.g. the clipboard of a
+// snippet may hold a password.
+func (e *IBusTelex) commitExpansion(str string) {
+	if str == "" {
+		return
+	}
+	e.personalLexicon.endText()
+	e.commitEncodedText(e.encodeText(str))
+}
+
+func (e *IBusTelex) commitEncodedText(str string) {
 	log.Printf("Commit Text [%s]\n", str)
-	e.CommitText(ibus.NewText(e.encodeText(str)))
+	e.CommitText(ibus.NewText(str))
 }
 
 func (e *IBusTelex) getVnSeq() string {
@@ -21,6 +21,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 	"time"
@@ -39,12 +40,18 @@ func GetIBusEngineCreator() func(*dbus.Conn, string) dbus.ObjectPath {
 		var engine = new(IBusTelex)
 		var config = loadConfig(engineName)
 		var objectPath = dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/IBus/Engine/%s/%d", engineName, time.Now().UnixNano()))
-		var inputMethod = core.ParseInputMethod(config.InputMethodDefinitions, config.InputMethod)
 		engine.Engine = ibus.BaseEngine(conn, objectPath)
 		engine.engineName = engineName
-		engine.preeditor = core.NewEngine(inputMethod, config.Flags)
-		engine.config = loadConfig(engineName)
-		engine.propList = GetPropListByConfig(config)
+		engine.config = config
+		engine.loadInputMethodFiles()
+		engine.loadLexicon()
+		engine.loadEnglishWords()
+		engine.loadRestoreOverrides()
+		engine.loadMacroTable()
+		engine.loadSnippets()
+		engine.loadPersonalLexicon()
+		engine.loadInputMethod()
+		engine.propList = GetPropListByConfig(config, engine.inputMethodFiles, "", engine.restoreOverrides)
 		ibus.PublishEngine(conn, objectPath, engine)
 		go engine.init()
 
@@ -97,6 +104,78 @@ func keyPressCapturing() {
 	}
 }
 
+func (e *IBusTelex) loadInputMethodFiles() {
+	var err error
+	e.inputMethodFiles, err = loadInputMethodFiles(e.engineName)
+	if err != nil {
+		log.Println(err)
+		showNotification("Input method file errors", summarizeErrors(err, 5))
+	}
+}
+
+func (e *IBusTelex) loadLexicon() {
+	var err error
+	e.lexicon, err = core.LoadLexicon(getLexiconPaths(e.engineName)...)
+	if err != nil {
+		log.Println(err)
+		showNotification("Lexicon errors", err.Error())
+	}
+	log.Printf("Loaded %d syllables", e.lexicon.Len())
+}
+
+// loadEnglishWords builds the list of English words to restore from the data
+// file and the additions and exceptions of the config.
+func (e *IBusTelex) loadEnglishWords() {
+	var err error
+	e.englishWords, err = core.LoadWordList(getEngineSubFile(DictEnglish))
+	if err != nil {
+		log.Println(err)
+	}
+	e.englishWords.Add(e.config.EnglishRestoreList...)
+	e.englishWords.Remove(e.config.EnglishRestoreExceptions...)
+}
+
+// getInputMethodDefinitions returns the definitions of the config and of the
+// definition files, a file overriding a config entry with the same name.
+func (e *IBusTelex) getInputMethodDefinitions() map[string]core.InputMethodDefinition {
+	var imDefs = make(map[string]core.InputMethodDefinition)
+	for name, imDef := range e.config.InputMethodDefinitions {
+		imDefs[name] = imDef
+	}
+	for name, f := range e.inputMethodFiles {
+		imDefs[name] = f.Definition
+	}
+	return imDefs
+}
+
+// loadInputMethod rebuilds the preeditor from the config. Problems in the input
+// method definitions are reported to the user instead of being silently dropped,
+// once until the input method or its definition changes.
+func (e *IBusTelex) loadInputMethod() {
+	var inputMethod core.InputMethod
+	var err error
+	if f, found := e.inputMethodFiles[e.config.InputMethod]; found {
+		// parsed from the file so that errors point to its lines
+		inputMethod, err = f.Parse()
+	} else {
+		inputMethod, err = core.ParseInputMethodWithErrors(e.getInputMethodDefinitions(), e.config.InputMethod)
+	}
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+	}
+	if errMsg != "" && errMsg != e.inputMethodErr {
+		log.Println(err)
+		showNotification("Input method errors", summarizeErrors(err, 5))
+	}
+	e.inputMethodErr = errMsg
+	if inputMethod.Name == "" {
+		inputMethod = core.ParseInputMethod(core.GetInputMethodDefinitions(), DefaultInputMethod)
+	}
+	e.preeditor = core.NewEngine(inputMethod, e.config.Flags)
+	e.preeditor.SetLexicon(e.lexicon)
+}
+
 func (e *IBusTelex) resetBuffer() {
 	if e.getRawKeyLen() == 0 {
 		return
@@ -214,7 +293,7 @@ func (e *IBusTelex) commitInputModeCandidate() {
 	e.config.InputModeMapping[e.wmClasses] = int(im)
 
 	saveConfig(e.config, e.engineName)
-	e.propList = GetPropListByConfig(e.config)
+	e.propList = GetPropListByConfig(e.config, e.inputMethodFiles, e.wmClasses, e.restoreOverrides)
 	e.RegisterProperties(e.propList)
 }
 
@@ -277,6 +356,10 @@ func notify(enMode bool) {
 		title = "English"
 		msg = "Press Shift to switch to Vietnamese"
 	}
+	showNotification(title, msg)
+}
+
+func showNotification(title, msg string) {
 	conn, err := dbus.SessionBus()
 	if err != nil {
 		fmt.Println(err)
@@ -52,9 +52,18 @@ const (
 	//IBUS_CAP_PROPERTY         = 1 << 4 //UI is capable to have property.
 	IBusCapSurroundingText = 1 << 5 //Client can provide surround text, or IME can handle surround text.
 )
+const (
+	//IBusInputPurpose
+	IBusInputPurposePassword = 8 //Like FREE_FORM, but characters are hidden.
+	IBusInputPurposePin      = 9 //Like DIGITS, but characters are hidden.
+
+	//IBusInputHints
+	IBusInputHintPrivate = 1 << 11 //The text must not be learned, e.g. in incognito windows.
+)
 const (
 	XkBackspace = 0x16
 	XkLeft      = 0x71
+	XkReturn    = 0x24
 )
 const (
 	IBusTab             = 0xff09
@@ -76,6 +85,7 @@ const (
 	IBusGrave           = 0x0060
 	IBusInsert          = 0xff63
 	IBusCapsLock        = 0xffe5
+	IBusF1              = 0xffbe
 	IBusOpenLookupTable = IBusTilde
 	IBusOpenEmojiTable  = IBusColon
 )
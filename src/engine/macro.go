@@ -0,0 +1,196 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/andodevel/ibus-telex/src/core"
+)
+
+// A MacroTable holds the abbreviations (gõ tắt) expanded at a word break. Its
+// file has one "abbreviation:text" line per macro, e.g. "vn:Việt Nam", and
+// lines starting with # are comments. A later line overrides an earlier one
+// with the same abbreviation.
+type MacroTable struct {
+	macros map[string]string
+}
+
+func NewMacroTable() *MacroTable {
+	return &MacroTable{macros: map[string]string{}}
+}
+
+// LoadMacroTable reads a macro file, a missing file giving an empty table.
+func LoadMacroTable(path string) (*MacroTable, error) {
+	var t = NewMacroTable()
+	var f, err = os.Open(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return t, err
+	}
+	defer f.Close()
+	if err = t.Read(f); err != nil {
+		return t, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+// Read adds the macros of a macro file.
+func (t *MacroTable) Read(r io.Reader) error {
+	var scanner = bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var i = strings.Index(line, ":")
+		if i <= 0 {
+			return fmt.Errorf("line %d: expected abbreviation:text, got %q", lineNumber, line)
+		}
+		t.Add(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+	}
+	return scanner.Err()
+}
+
+func (t *MacroTable) Add(key, text string) {
+	t.macros[key] = text
+}
+
+func (t *MacroTable) Get(key string) (string, bool) {
+	var text, found = t.macros[key]
+	return text, found
+}
+
+// Keys returns the abbreviations in alphabetical order.
+func (t *MacroTable) Keys() []string {
+	var keys []string
+	for key := range t.macros {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (t *MacroTable) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.macros)
+}
+
+// Lookup returns the expansion of word. With autoCapitalize, a word that only
+// differs from a lowercase abbreviation by its case gets the same case, e.g.
+// "Vn" gives "Việt Nam" and "VN" gives "VIỆT NAM".
+func (t *MacroTable) Lookup(word string, autoCapitalize bool) (string, bool) {
+	if t.Len() == 0 || word == "" {
+		return "", false
+	}
+	if text, found := t.macros[word]; found {
+		return text, true
+	}
+	var lowerWord = strings.ToLower(word)
+	if !autoCapitalize || lowerWord == word {
+		return "", false
+	}
+	var text, found = t.macros[lowerWord]
+	if !found {
+		return "", false
+	}
+	return matchCase(word, text), true
+}
+
+// matchCase gives text the case of word: upper case if word is, which takes
+// two letters, or capitalized if word is.
+func matchCase(word, text string) string {
+	if utf8.RuneCountInString(word) > 1 && strings.ToUpper(word) == word {
+		return strings.ToUpper(text)
+	}
+	if first, _ := utf8.DecodeRuneInString(word); unicode.IsUpper(first) && text != "" {
+		var textFirst, size = utf8.DecodeRuneInString(text)
+		return string(unicode.ToUpper(textFirst)) + text[size:]
+	}
+	return text
+}
+
+func getMacroPath(engineName string) string {
+	return fmt.Sprintf(macroFile, getConfigDir(engineName), engineName)
+}
+
+// loadMacroTable reads the macro file again when it changed since it was
+// last read, e.g. after being edited from the menu.
+func (e *IBusTelex) loadMacroTable() {
+	var path = getMacroPath(e.engineName)
+	var modTime int64
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().UnixNano()
+	}
+	if e.macroTable != nil && modTime == e.macroModTime {
+		return
+	}
+	var t, err = LoadMacroTable(path)
+	if err != nil {
+		log.Println(err)
+		showNotification("Macro file errors", err.Error())
+	}
+	e.macroTable = t
+	e.macroModTime = modTime
+	log.Printf("Loaded %d macros", t.Len())
+}
+
+// createMacroFile writes an example macro file if the user has none, so that
+// there is something to open in an editor.
+func createMacroFile(engineName string) {
+	var path = getMacroPath(engineName)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return
+	}
+	setupConfigDir(engineName)
+	var example = "# Gõ tắt: mỗi dòng có dạng \"từ viết tắt:nội dung\"\n# vn:Việt Nam\n# ko:không\n"
+	if err := ioutil.WriteFile(path, []byte(example), 0644); err != nil {
+		log.Println(err)
+	}
+}
+
+// expandMacro returns the expansion of the word that ends, which is looked up
+// as shown and then as typed, so that "dd" can be an abbreviation as well as
+// "đ".
+func (e *IBusTelex) expandMacro(text string) (string, bool) {
+	if e.config.JupiterFlags&JmacroEnabled == 0 {
+		return "", false
+	}
+	var autoCapitalize = e.config.JupiterFlags&JmacroAutoCapitalize != 0
+	for _, word := range []string{text, e.getProcessedString(core.EnglishMode)} {
+		if expanded, found := e.macroTable.Lookup(word, autoCapitalize); found {
+			return expanded, true
+		}
+	}
+	return "", false
+}
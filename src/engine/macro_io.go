@@ -0,0 +1,259 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/andodevel/ibus-telex/src/core"
+)
+
+// The macro file formats that can be imported and exported. All of them have
+// one "abbreviation:text" line per macro. Unikey files start with a header
+// line and may have ; comments, and EVKey reads and writes Unikey files.
+// Bamboo files are the format of our own macro file.
+const (
+	MacroFormatUnikey = "unikey"
+	MacroFormatEVKey  = "evkey"
+	MacroFormatBamboo = "bamboo"
+)
+
+// The ways to solve an imported macro whose abbreviation is already defined.
+const (
+	MacroConflictKeep    = "keep"
+	MacroConflictReplace = "replace"
+)
+
+const unikeyMacroHeader = ";DO NOT DELETE THIS LINE*** version=1 ***"
+
+// importedMacros are the macros of a file being imported, in file order. An
+// abbreviation defined twice gets the last text.
+type importedMacros struct {
+	path     string
+	charset  string
+	macros   []macro
+	warnings []string
+}
+
+type macro struct {
+	key  string
+	text string
+}
+
+// readMacroFile parses a macro file of any supported format, guessing its
+// charset when it is not given: UTF-16 or UTF-8 with a byte order mark, UTF-8,
+// or else the legacy charset that gives the most Vietnamese words.
+func readMacroFile(path, charset string) (*importedMacros, error) {
+	var data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f = &importedMacros{path: path}
+	var text string
+	text, f.charset, err = decodeMacroData(data, charset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var lines = strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	var lineNumbers = map[string]int{}
+	var indexes = map[string]int{}
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		var j = strings.Index(line, ":")
+		if j <= 0 {
+			f.warnings = append(f.warnings, fmt.Sprintf("%s:%d: skipped, expected abbreviation:text", path, i+1))
+			continue
+		}
+		var m = macro{key: strings.TrimSpace(line[:j]), text: strings.TrimSpace(line[j+1:])}
+		if n, found := lineNumbers[m.key]; found {
+			f.warnings = append(f.warnings, fmt.Sprintf("%s:%d: %q is already defined on line %d, the last one is kept", path, i+1, m.key, n))
+			f.macros[indexes[m.key]] = m
+		} else {
+			indexes[m.key] = len(f.macros)
+			f.macros = append(f.macros, m)
+		}
+		lineNumbers[m.key] = i + 1
+	}
+	return f, nil
+}
+
+func decodeMacroData(data []byte, charset string) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+		charset = core.UNICODE
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return core.Decode(core.UnicodeNFD, decodeUTF16(data[2:], false)), "UTF-16LE", nil
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return core.Decode(core.UnicodeNFD, decodeUTF16(data[2:], true)), "UTF-16BE", nil
+	}
+	if charset == "" {
+		charset = core.UNICODE
+		if !utf8.Valid(data) {
+			var guess = core.DetectCharset(data)[0]
+			if guess.Confidence == 0 || !core.IsLegacyCharset(guess.Charset) {
+				return "", "", fmt.Errorf("unknown charset, see -macro-charset")
+			}
+			charset = guess.Charset
+		}
+	}
+	if !isValidCharset(charset) {
+		return "", "", fmt.Errorf("unknown charset %q", charset)
+	}
+	var text = string(data)
+	if core.IsLegacyCharset(charset) {
+		var runes = make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+		return core.Decode(charset, text), charset, nil
+	}
+	// the letters of decomposed text are composed
+	return core.Decode(core.UnicodeNFD, text), charset, nil
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	var units = make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// importMacros adds the macros of the given files to the macro file at path,
+// solving conflicts as told, and prints what it does to w. It returns the exit
+// status. The new macros are appended so that the comments of the file stay.
+func importMacros(w io.Writer, path string, files []string, charset, conflict string) int {
+	if conflict != MacroConflictKeep && conflict != MacroConflictReplace {
+		fmt.Fprintf(os.Stderr, "unknown conflict policy %q, expected %s or %s\n", conflict, MacroConflictKeep, MacroConflictReplace)
+		return 2
+	}
+	var t, err = LoadMacroTable(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var status = 0
+	var b strings.Builder
+	for _, name := range files {
+		var f, err = readMacroFile(name, charset)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		for _, warning := range f.warnings {
+			fmt.Fprintln(os.Stderr, warning)
+		}
+		var added strings.Builder
+		var nAdded, nConflicts = 0, 0
+		for _, m := range f.macros {
+			if text, found := t.Get(m.key); found && text == m.text {
+				continue
+			} else if found {
+				nConflicts++
+				fmt.Fprintf(os.Stderr, "%s: %q is already defined as %q\n", name, m.key, text)
+				if conflict == MacroConflictKeep {
+					continue
+				}
+			}
+			t.Add(m.key, m.text)
+			fmt.Fprintf(&added, "%s:%s\n", m.key, m.text)
+			nAdded++
+		}
+		if nAdded > 0 {
+			fmt.Fprintf(&b, "# %s (%s)\n%s", name, f.charset, added.String())
+		}
+		fmt.Fprintf(w, "%s: %d macros added, %d conflicts (%s)\n", name, nAdded, nConflicts, conflict)
+	}
+	if b.Len() == 0 {
+		return status
+	}
+	if err = appendMacroFile(path, b.String()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return status
+}
+
+func appendMacroFile(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	var f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		text = "\n" + text
+	}
+	if _, err = f.WriteString(text); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportMacros writes the macros of the macro file at path in the given
+// format. Unikey and EVKey files are written as UTF-8 with a byte order mark
+// and CRLF line ends, as Windows tools expect.
+func exportMacros(w io.Writer, path, format string) int {
+	var t, err = LoadMacroTable(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var b strings.Builder
+	var eol = "\n"
+	switch format {
+	case MacroFormatUnikey, MacroFormatEVKey:
+		eol = "\r\n"
+		b.WriteString("\ufeff" + unikeyMacroHeader + eol)
+	case MacroFormatBamboo:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown macro format %q, expected %s, %s or %s\n", format, MacroFormatUnikey, MacroFormatEVKey, MacroFormatBamboo)
+		return 2
+	}
+	for _, key := range t.Keys() {
+		var text, _ = t.Get(key)
+		fmt.Fprintf(&b, "%s:%s%s", key, text, eol)
+	}
+	if _, err = io.WriteString(w, b.String()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
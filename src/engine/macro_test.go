@@ -0,0 +1,226 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andodevel/ibus-telex/src/core"
+)
+
+func writeMacroTestFile(t *testing.T, data []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "telex-macro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var path = filepath.Join(dir, "macros.txt")
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// toLegacyBytes gives the bytes of text encoded in a legacy charset.
+func toLegacyBytes(charset, text string) []byte {
+	var data []byte
+	for _, r := range core.Encode(charset, text) {
+		data = append(data, byte(r))
+	}
+	return data
+}
+
+func TestMacroTableRead(t *testing.T) {
+	var tests = []struct {
+		name     string
+		file     string
+		expected map[string]string
+		err      string
+	}{
+		{"comments", "# Gõ tắt\n\nvn:Việt Nam\n  # ko:không\n", map[string]string{"vn": "Việt Nam"}, ""},
+		{"spaces", " hn : Hà Nội \n", map[string]string{"hn": "Hà Nội"}, ""},
+		{"override", "vn:VN\nvn:Việt Nam\n", map[string]string{"vn": "Việt Nam"}, ""},
+		{"bad line", "vn:Việt Nam\nkhông\n", nil, "line 2"},
+		{"no abbreviation", ":Việt Nam\n", nil, "line 1"},
+	}
+	for _, test := range tests {
+		var m = NewMacroTable()
+		var err = m.Read(strings.NewReader(test.file))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Read %s. Got error %v, expected %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Read %s. Got error %v", test.name, err)
+		}
+		if !reflect.DeepEqual(m.macros, test.expected) {
+			t.Errorf("Read %s. Got %v, expected %v", test.name, m.macros, test.expected)
+		}
+	}
+}
+
+func TestReadMacroFile(t *testing.T) {
+	var tests = []struct {
+		name     string
+		data     []byte
+		charset  string
+		expected []macro
+		warnings []string
+	}{
+		{
+			name:     "Unikey",
+			data:     []byte("\ufeff" + unikeyMacroHeader + "\r\n; comment\r\nvn:Việt Nam\r\n"),
+			charset:  "Unicode",
+			expected: []macro{{"vn", "Việt Nam"}},
+		},
+		{
+			name:     "bad line",
+			data:     []byte("# Bamboo\nvn:Việt Nam\nkhông\nko:không\n"),
+			charset:  "Unicode",
+			expected: []macro{{"vn", "Việt Nam"}, {"ko", "không"}},
+			warnings: []string{":3: skipped, expected abbreviation:text"},
+		},
+		{
+			name:     "defined twice",
+			data:     []byte("vn:VN\nvn:Việt Nam\n"),
+			charset:  "Unicode",
+			expected: []macro{{"vn", "Việt Nam"}},
+			warnings: []string{`:2: "vn" is already defined on line 1, the last one is kept`},
+		},
+		{
+			name:     "TCVN3",
+			data:     toLegacyBytes(core.TCVN3, "vn:Việt Nam\nko:không\n"),
+			charset:  core.TCVN3,
+			expected: []macro{{"vn", "Việt Nam"}, {"ko", "không"}},
+		},
+		{
+			name:     "decomposed",
+			data:     []byte(core.Encode(core.UnicodeNFD, "vn:Việt Nam\n")),
+			charset:  "Unicode",
+			expected: []macro{{"vn", "Việt Nam"}},
+		},
+	}
+	for _, test := range tests {
+		var path, cleanup = writeMacroTestFile(t, test.data)
+		var f, err = readMacroFile(path, "")
+		cleanup()
+		if err != nil {
+			t.Errorf("Read the %s file. Got error %v", test.name, err)
+			continue
+		}
+		if f.charset != test.charset {
+			t.Errorf("Read the %s file. Got charset %s, expected %s", test.name, f.charset, test.charset)
+		}
+		if !reflect.DeepEqual(f.macros, test.expected) {
+			t.Errorf("Read the %s file. Got %v, expected %v", test.name, f.macros, test.expected)
+		}
+		if len(f.warnings) != len(test.warnings) {
+			t.Errorf("Read the %s file. Got warnings %q, expected %q", test.name, f.warnings, test.warnings)
+			continue
+		}
+		for i, warning := range test.warnings {
+			if !strings.HasSuffix(f.warnings[i], warning) {
+				t.Errorf("Read the %s file. Got warning %q, expected %q", test.name, f.warnings[i], warning)
+			}
+		}
+	}
+}
+
+func TestDecodeMacroData(t *testing.T) {
+	var tests = []struct {
+		name     string
+		data     []byte
+		charset  string
+		expected string
+		err      bool
+	}{
+		{"UTF-8", []byte("vn:Việt Nam"), "", "vn:Việt Nam", false},
+		{"UTF-16LE", []byte{0xFF, 0xFE, 'v', 0, 'n', 0, ':', 0, 0xB0, 0x01}, "", "vn:ư", false},
+		{"UTF-16BE", []byte{0xFE, 0xFF, 0, 'v', 0, 'n', 0, ':', 0x01, 0xB0}, "", "vn:ư", false},
+		{"VISCII given", toLegacyBytes(core.VISCII, "ko:không"), core.VISCII, "ko:không", false},
+		{"unknown charset", []byte("vn:Việt Nam"), "EBCDIC", "", true},
+	}
+	for _, test := range tests {
+		var text, _, err = decodeMacroData(test.data, test.charset)
+		if (err != nil) != test.err {
+			t.Errorf("Decode %s. Got error %v, expected error %v", test.name, err, test.err)
+			continue
+		}
+		if text != test.expected {
+			t.Errorf("Decode %s. Got %q, expected %q", test.name, text, test.expected)
+		}
+	}
+}
+
+func TestMacroTableLookup(t *testing.T) {
+	var m = NewMacroTable()
+	m.Add("vn", "Việt Nam")
+	m.Add("đc", "được")
+	m.Add("KO", "không")
+	var tests = []struct {
+		word           string
+		autoCapitalize bool
+		expected       string
+		found          bool
+	}{
+		{"vn", true, "Việt Nam", true},
+		{"Vn", true, "Việt Nam", true},
+		{"VN", true, "VIỆT NAM", true},
+		{"Đc", true, "Được", true},
+		{"ĐC", true, "ĐƯỢC", true},
+		{"VN", false, "", false},
+		{"vN", true, "Việt Nam", true},
+		{"KO", true, "không", true},
+		{"ko", true, "", false},
+		{"", true, "", false},
+	}
+	for _, test := range tests {
+		var text, found = m.Lookup(test.word, test.autoCapitalize)
+		if text != test.expected || found != test.found {
+			t.Errorf("Lookup %q (autoCapitalize %v). Got %q %v, expected %q %v", test.word, test.autoCapitalize, text, found, test.expected, test.found)
+		}
+	}
+}
+
+func TestMatchCase(t *testing.T) {
+	var tests = []struct {
+		word     string
+		text     string
+		expected string
+	}{
+		{"vn", "việt nam", "việt nam"},
+		{"Vn", "việt nam", "Việt nam"},
+		{"VN", "việt nam", "VIỆT NAM"},
+		{"Đ", "đường", "Đường"},
+		{"đ", "đường", "đường"},
+		{"Ko", "", ""},
+	}
+	for _, test := range tests {
+		if got := matchCase(test.word, test.text); got != test.expected {
+			t.Errorf("matchCase %q %q. Got %q, expected %q", test.word, test.text, got, test.expected)
+		}
+	}
+}
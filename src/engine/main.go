@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/BambooEngine/goibus/ibus"
 	abus "github.com/andodevel/ibus-telex/src/ibus"
@@ -36,6 +37,14 @@ const (
 
 var embedded = flag.Bool("ibus", false, "Run the embedded ibus component")
 var version = flag.Bool("version", false, "Show version")
+var checkIM = flag.Bool("check-im", false, "Check the input methods of the given config files (default: the user's config) and exit")
+var importMacroFiles = flag.Bool("import-macros", false, "Import the given Unikey, EVKey or Bamboo macro files into the macro file and exit")
+var exportMacroFile = flag.Bool("export-macros", false, "Print the macros in -macro-format and exit")
+var macroPath = flag.String("macro-file", "", "Macro file to import into or export (default: the user's)")
+var macroFormat = flag.String("macro-format", MacroFormatBamboo, "Format of the exported macros: unikey, evkey or bamboo")
+var macroCharset = flag.String("macro-charset", "", "Charset of the imported files (default: detected)")
+var macroConflict = flag.String("macro-conflict", MacroConflictKeep, "What to do with an imported abbreviation that is already defined: keep or replace")
+var overrides = flag.Bool("restore-overrides", false, "List the words learned by auto-restore, after forgetting the given ones, and exit")
 
 func main() {
 	flag.Parse()
@@ -44,6 +53,21 @@ func main() {
 	}
 	if *version {
 		fmt.Println(Version)
+	} else if *checkIM {
+		if checkInputMethods(os.Stdout, flag.Args()) > 0 {
+			os.Exit(1)
+		}
+	} else if *importMacroFiles || *exportMacroFile {
+		var path = *macroPath
+		if path == "" {
+			path = getMacroPath(strings.ToLower(EngineName))
+		}
+		if *importMacroFiles {
+			os.Exit(importMacros(os.Stdout, path, flag.Args(), *macroCharset, *macroConflict))
+		}
+		os.Exit(exportMacros(os.Stdout, path, *macroFormat))
+	} else if *overrides {
+		os.Exit(printRestoreOverrides(os.Stdout, flag.Args()))
 	} else if *embedded {
 		engine := GetIBusEngineCreator()
 		bus := ibus.NewBus()
@@ -0,0 +1,302 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BambooEngine/goibus/ibus"
+)
+
+const personalLexiconFile = "personal-lexicon.json"
+
+const (
+	minCompletionPrefixLen = 2  // letters typed before completions are shown
+	maxLearnedWordLen      = 30 // longer words are rather links or hashes
+	lexiconSaveInterval    = 20 // words learned between two saves
+)
+
+// A personalLexicon counts the words, and the two-word phrases, the user
+// commits so that the words they use the most are completed first, e.g.
+// "ngườ" gives "người" and "người dùng". Words are counted in lower case.
+type personalLexicon struct {
+	counts       map[string]int
+	trie         *TrieNode // built on the first completion
+	word         []rune    // the letters committed since the last word ended
+	previousWord string    // the last word learned, if only spaces followed it
+	nUnsaved     int
+}
+
+func newPersonalLexicon() *personalLexicon {
+	return &personalLexicon{counts: map[string]int{}}
+}
+
+func getPersonalLexiconPath(ngName string) string {
+	return filepath.Join(getConfigDir(ngName), personalLexiconFile)
+}
+
+// loadPersonalLexicon reads the lexicon file, a missing file meaning that
+// nothing was learned yet.
+func loadPersonalLexicon(ngName string) (*personalLexicon, error) {
+	var l = newPersonalLexicon()
+	var path = getPersonalLexiconPath(ngName)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	if err = json.Unmarshal(data, &l.counts); err != nil {
+		return newPersonalLexicon(), fmt.Errorf("%s: %v", path, err)
+	}
+	return l, nil
+}
+
+func (l *personalLexicon) save(ngName string) error {
+	data, err := json.MarshalIndent(l.counts, "", "  ")
+	if err != nil {
+		return err
+	}
+	setupConfigDir(ngName)
+	var path = getPersonalLexiconPath(ngName)
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of a file saved by older versions
+	if err = os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	l.nUnsaved = 0
+	return nil
+}
+
+func (l *personalLexicon) add(entry string) {
+	if l.counts[entry] == 0 && l.trie != nil {
+		InsertTrie(l.trie, entry, entry)
+	}
+	l.counts[entry]++
+	l.nUnsaved++
+}
+
+// learnText counts the words of committed text. A word ends at the first
+// character that is not a letter, which may come with the next commit, and it
+// makes a phrase with the word before when only spaces are between them.
+func (l *personalLexicon) learnText(text string) {
+	for _, chr := range text {
+		if unicode.IsLetter(chr) || unicode.Is(unicode.Mn, chr) {
+			l.word = append(l.word, chr)
+			continue
+		}
+		l.endWord()
+		if chr != ' ' {
+			l.previousWord = ""
+		}
+	}
+}
+
+func (l *personalLexicon) endWord() {
+	var n = len(l.word)
+	if n == 0 {
+		return
+	}
+	var w = strings.ToLower(string(l.word))
+	l.word = nil
+	if n < minCompletionPrefixLen || n > maxLearnedWordLen {
+		l.previousWord = ""
+		return
+	}
+	l.add(w)
+	if l.previousWord != "" {
+		l.add(l.previousWord + " " + w)
+	}
+	l.previousWord = w
+}
+
+// endText ends the word being committed and the phrase, e.g. when typing goes
+// on in another field.
+func (l *personalLexicon) endText() {
+	l.endWord()
+	l.previousWord = ""
+}
+
+// findCandidates returns the words and phrases starting with prefix, the most
+// used first, in the case of prefix.
+func (l *personalLexicon) findCandidates(prefix string) []candidate {
+	if utf8.RuneCountInString(prefix) < minCompletionPrefixLen {
+		return nil
+	}
+	if l.trie == nil {
+		l.trie = NewTrie()
+		for entry := range l.counts {
+			InsertTrie(l.trie, entry, entry)
+		}
+	}
+	var lowerPrefix = strings.ToLower(prefix)
+	var entries []string
+	for entry := range FindPrefix(l.trie, lowerPrefix) {
+		if entry != lowerPrefix {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		var a, b = entries[i], entries[j]
+		if l.counts[a] != l.counts[b] {
+			return l.counts[a] > l.counts[b]
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	if len(entries) > candidatePageSize {
+		entries = entries[:candidatePageSize]
+	}
+	var candidates []candidate
+	for _, entry := range entries {
+		var text = matchCase(prefix, entry)
+		candidates = append(candidates, candidate{text: text, label: text})
+	}
+	return candidates
+}
+
+// Completions are chosen while typing, no key is part of a query.
+func (l *personalLexicon) isQueryKey(key rune) bool {
+	return false
+}
+
+func (l *personalLexicon) selectsWithDigits() bool {
+	return false
+}
+
+func (e *IBusTelex) loadPersonalLexicon() {
+	var err error
+	e.personalLexicon, err = loadPersonalLexicon(e.engineName)
+	if err != nil {
+		log.Println(err)
+		showNotification("Personal lexicon errors", err.Error())
+	}
+}
+
+func (e *IBusTelex) savePersonalLexicon() {
+	if e.personalLexicon.nUnsaved == 0 {
+		return
+	}
+	if err := e.personalLexicon.save(e.engineName); err != nil {
+		log.Println(err)
+	}
+}
+
+// canLearnWords reports whether what is typed may be learned: only in the
+// applications whose completions the user turned on, never in password fields
+// nor in the applications the user excluded. Terminals do not tell their
+// password prompts apart, e.g. sudo, so nothing is learned by default.
+func (e *IBusTelex) canLearnWords() bool {
+	if e.contentPurpose == IBusInputPurposePassword || e.contentPurpose == IBusInputPurposePin ||
+		e.contentHints&IBusInputHintPrivate != 0 {
+		return false
+	}
+	return inStringList(e.config.AutocompleteWhiteList, e.wmClasses) &&
+		!inStringList(e.config.LearningExceptedList, e.wmClasses)
+}
+
+// learnText adds the words of committed text to the personal lexicon, which
+// is saved every few words and when the focus goes out.
+func (e *IBusTelex) learnText(text string) {
+	if !e.canLearnWords() {
+		e.personalLexicon.endText()
+		return
+	}
+	e.personalLexicon.learnText(text)
+	if e.personalLexicon.nUnsaved >= lexiconSaveInterval {
+		e.savePersonalLexicon()
+	}
+}
+
+// isAutocompleteEnabled reports whether completions are shown, only in
+// pre-edit mode and where words are learned.
+func (e *IBusTelex) isAutocompleteEnabled() bool {
+	return e.checkInputMode(preeditIM) && e.canLearnWords()
+}
+
+// updateCompletions shows the completions of the word being typed, if any.
+func (e *IBusTelex) updateCompletions(text string) {
+	if !e.isAutocompleteEnabled() || e.candidateWindow != nil {
+		e.hideCompletions()
+		return
+	}
+	var w = &candidateWindow{source: e.personalLexicon, query: []rune(text)}
+	w.refresh()
+	if len(w.candidates) == 0 {
+		e.hideCompletions()
+		return
+	}
+	e.completionWindow = w
+	e.showCandidateTable(w)
+}
+
+func (e *IBusTelex) hideCompletions() {
+	if e.completionWindow == nil {
+		return
+	}
+	e.completionWindow = nil
+	e.UpdateLookupTable(ibus.NewLookupTable(), true) // workaround for issue #18
+	e.HideLookupTable()
+}
+
+// commitCompletion replaces the word being typed by the completion under the
+// cursor, which is not learned again.
+func (e *IBusTelex) commitCompletion() {
+	var w = e.completionWindow
+	if pos := int(w.table.CursorPos); pos < len(w.candidates) {
+		e.resetPreedit()
+		e.commitExpansion(w.candidates[pos].text)
+	}
+}
+
+// completionProcessKeyEvent handles the keys choosing a completion: Up and
+// Down move in the list and Tab commits. Other keys go on typing.
+func (e *IBusTelex) completionProcessKeyEvent(keyVal, state uint32) bool {
+	if !e.isValidState(state) || state&IBusShiftMask != 0 {
+		return false
+	}
+	switch keyVal {
+	case IBusTab:
+		e.commitCompletion()
+	case IBusUp:
+		e.CursorUp()
+	case IBusDown:
+		e.CursorDown()
+	case IBusEscape:
+		e.hideCompletions()
+	default:
+		return false
+	}
+	return true
+}
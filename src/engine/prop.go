@@ -20,7 +20,12 @@
 package main
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/BambooEngine/goibus/ibus"
+	"github.com/andodevel/ibus-telex/src/core"
 )
 
 const (
@@ -28,8 +33,221 @@ const (
 	PropKeyStdToneStyle   = "std_tone_style"
 	PropKeyMouseCapturing = "mouse_capturing"
 	PropKeyConfiguration  = "configuration"
+	PropKeyCharset        = "charset"
+	PropKeyAppCharset     = "app_charset"
+	PropKeySpellCheck     = "spell_check"
+
+	PropKeyMacroEnabled        = "macro_enabled"
+	PropKeyMacroAutoCapitalize = "macro_auto_capitalize"
+	PropKeyMacroEdit           = "macro_edit"
+	PropKeySnippetsEdit        = "snippets_edit"
+	PropKeyEmojiEnabled        = "emoji_enabled"
+
+	PropKeyAutocomplete     = "autocomplete"
+	PropKeyLearningExcepted = "learning_excepted"
+
+	PropKeyRestoreOverride       = "restore_override"
+	PropKeyRestoreOverridesClear = "restore_overrides_clear"
+
+	PropKeyClipboardConvert = "clipboard_convert"
+	PropKeyClipboardAction  = "clipboard_action"
+	PropKeyClipboardFrom    = "clipboard_from"
+	PropKeyClipboardTo      = "clipboard_to"
 )
 
-func GetPropListByConfig(c *Config) *ibus.PropList {
-	return ibus.NewPropList()
+func GetPropListByConfig(c *Config, imFiles map[string]*core.InputMethodFile, wmClasses string, overrides restoreOverrides) *ibus.PropList {
+	var props = []*ibus.Property{
+		GetIMPropByConfig(c, imFiles),
+		GetCharsetPropByConfig(c),
+	}
+	if wmClasses != "" {
+		props = append(props, GetAppCharsetPropByConfig(c, wmClasses), GetAutocompletePropByConfig(c, wmClasses))
+	}
+	props = append(props, GetSpellCheckPropByConfig(c), GetRestoreOverridesProp(overrides), GetMacroPropByConfig(c), GetClipboardPropByConfig(c))
+	return ibus.NewPropList(props...)
+}
+
+// GetCharsetPropByConfig builds the output charset menu, the keys of its items
+// being parsed back by getCharsetFromPropKey.
+func GetCharsetPropByConfig(c *Config) *ibus.Property {
+	var csProps []*ibus.Property
+	for _, charset := range core.GetCharsetNames() {
+		var state = ibus.PROP_STATE_UNCHECKED
+		if charset == c.OutputCharset {
+			state = ibus.PROP_STATE_CHECKED
+		}
+		csProps = append(csProps, ibus.NewProperty(PropKeyCharset+"::"+charset, ibus.PROP_TYPE_RADIO, charset, "", charset, true, true, state))
+	}
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, "Bảng mã: "+c.OutputCharset, "", "Chọn bảng mã", true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(csProps...))
+}
+
+// GetIMPropByConfig builds the input method menu from the methods of the config
+// and of the definition files.
+func GetIMPropByConfig(c *Config, imFiles map[string]*core.InputMethodFile) *ibus.Property {
+	var imNames []string
+	for imName := range c.InputMethodDefinitions {
+		if _, found := imFiles[imName]; !found {
+			imNames = append(imNames, imName)
+		}
+	}
+	for imName := range imFiles {
+		imNames = append(imNames, imName)
+	}
+	sort.Strings(imNames)
+
+	var imProps []*ibus.Property
+	for _, imName := range imNames {
+		var state = ibus.PROP_STATE_UNCHECKED
+		if imName == c.InputMethod {
+			state = ibus.PROP_STATE_CHECKED
+		}
+		var tooltip = imName
+		if f, found := imFiles[imName]; found {
+			tooltip = getIMFileTooltip(f)
+		}
+		imProps = append(imProps, ibus.NewProperty(imName, ibus.PROP_TYPE_RADIO, imName, "", tooltip, true, true, state))
+	}
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, "Kiểu gõ: "+c.InputMethod, "", "Chọn kiểu gõ", true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(imProps...))
+}
+
+func getIMFileTooltip(f *core.InputMethodFile) string {
+	var lines []string
+	for _, line := range []string{f.Description, f.Author, f.Path} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// GetAppCharsetPropByConfig builds the menu mapping a charset to the focused
+// application, e.g. to only get escape sequences in a code editor.
+func GetAppCharsetPropByConfig(c *Config, wmClasses string) *ibus.Property {
+	var mapped, found = c.OutputCharsetMapping[wmClasses]
+	var state = ibus.PROP_STATE_UNCHECKED
+	if !found {
+		state = ibus.PROP_STATE_CHECKED
+	}
+	var csProps = []*ibus.Property{
+		ibus.NewProperty(PropKeyAppCharset+"::", ibus.PROP_TYPE_RADIO, "Theo bảng mã chung", "", c.OutputCharset, true, true, state),
+	}
+	for _, charset := range core.GetCharsetNames() {
+		var state = ibus.PROP_STATE_UNCHECKED
+		if found && charset == mapped {
+			state = ibus.PROP_STATE_CHECKED
+		}
+		csProps = append(csProps, ibus.NewProperty(PropKeyAppCharset+"::"+charset, ibus.PROP_TYPE_RADIO, charset, "", charset, true, true, state))
+	}
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, "Bảng mã cho "+wmClasses, "", wmClasses, true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(csProps...))
+}
+
+// GetAutocompletePropByConfig builds the menu turning on the completions of
+// the focused application, which the learning of its words goes with.
+func GetAutocompletePropByConfig(c *Config, wmClasses string) *ibus.Property {
+	var enabled = inStringList(c.AutocompleteWhiteList, wmClasses)
+	var learning = !inStringList(c.LearningExceptedList, wmClasses)
+	var label = "Gợi ý từ: tắt"
+	if enabled && learning {
+		label = "Gợi ý từ: bật"
+	}
+	var props = []*ibus.Property{
+		ibus.NewProperty(PropKeyAutocomplete, ibus.PROP_TYPE_TOGGLE, "Gợi ý từ cho "+wmClasses, "", "Tab để chọn, ↑↓ để chuyển (chỉ ở chế độ Pre-edit)", learning, true, getRadioState(enabled)),
+		ibus.NewProperty(PropKeyLearningExcepted, ibus.PROP_TYPE_TOGGLE, "Không học từ trong "+wmClasses, "", "Không ghi nhớ các từ gõ trong ứng dụng này", enabled, true, getRadioState(!learning)),
+	}
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, label, "", "Gợi ý từ theo các từ hay dùng", true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(props...))
+}
+
+// GetSpellCheckPropByConfig builds the menu choosing how auto-restore decides
+// that a word is not Vietnamese.
+func GetSpellCheckPropByConfig(c *Config) *ibus.Property {
+	var mode = getSpellCheckMode(c.Flags)
+	var props []*ibus.Property
+	for _, m := range []string{SpellCheckPhonotactic, SpellCheckDictionary, SpellCheckOff} {
+		props = append(props, ibus.NewProperty(PropKeySpellCheck+"::"+m, ibus.PROP_TYPE_RADIO, spellCheckModeLabels[m], "", spellCheckModeLabels[m], true, true, getRadioState(m == mode)))
+	}
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, "Chính tả: "+spellCheckModeLabels[mode], "", "Kiểm tra chính tả", true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(props...))
+}
+
+// GetMacroPropByConfig builds the macro (gõ tắt) menu, which also opens the
+// snippets and toggles emojis.
+func GetMacroPropByConfig(c *Config) *ibus.Property {
+	var enabled = c.JupiterFlags&JmacroEnabled != 0
+	var label = "Gõ tắt: tắt"
+	if enabled {
+		label = "Gõ tắt: bật"
+	}
+	var props = []*ibus.Property{
+		ibus.NewProperty(PropKeyMacroEnabled, ibus.PROP_TYPE_TOGGLE, "Bật gõ tắt", "", "Thay từ viết tắt khi kết thúc từ", true, true, getRadioState(enabled)),
+		ibus.NewProperty(PropKeyMacroAutoCapitalize, ibus.PROP_TYPE_TOGGLE, "Tự viết hoa", "", "Vn → Việt Nam, VN → VIỆT NAM", enabled, true, getRadioState(c.JupiterFlags&JmacroAutoCapitalize != 0)),
+		ibus.NewProperty(PropKeyMacroEdit, ibus.PROP_TYPE_NORMAL, "Sửa bảng gõ tắt", "", "Mở bảng gõ tắt", true, true, ibus.PROP_STATE_UNCHECKED),
+		ibus.NewProperty(PropKeyEmojiEnabled, ibus.PROP_TYPE_TOGGLE, "Emoji (:smile:)", "", "Gõ : ở đầu từ để chọn emoji", true, true, getRadioState(c.JupiterFlags&JemojiEnabled != 0)),
+		ibus.NewProperty(PropKeySnippetsEdit, ibus.PROP_TYPE_NORMAL, "Sửa mẫu văn bản", "", "Mở thư mục mẫu văn bản (ngày, giờ, clipboard...)", true, true, ibus.PROP_STATE_UNCHECKED),
+	}
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, label, "", "Gõ tắt", true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(props...))
+}
+
+// GetRestoreOverridesProp builds the menu of the words the user forced into
+// Vietnamese or English, an item forgetting its word when clicked.
+func GetRestoreOverridesProp(overrides restoreOverrides) *ibus.Property {
+	var props []*ibus.Property
+	for _, keys := range overrides.sortedKeys() {
+		var label = keys + ": " + overrideLabels[overrides[keys]]
+		props = append(props, ibus.NewProperty(PropKeyRestoreOverride+"::"+keys, ibus.PROP_TYPE_NORMAL, label, "", "Bấm để quên từ này", true, true, ibus.PROP_STATE_UNCHECKED))
+	}
+	props = append(props, ibus.NewProperty(PropKeyRestoreOverridesClear, ibus.PROP_TYPE_NORMAL, "Quên tất cả", "", "Quên tất cả các từ đã học", len(overrides) > 0, true, ibus.PROP_STATE_UNCHECKED))
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, fmt.Sprintf("Từ đã học: %d", len(overrides)), "", "Các từ đã sửa khi tự khôi phục", true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(props...))
+}
+
+// GetClipboardPropByConfig builds the clipboard conversion menu: the action run
+// by the hotkey, the charsets it converts between and an item to run it now.
+func GetClipboardPropByConfig(c *Config) *ibus.Property {
+	var label = "Chuyển mã clipboard"
+	if c.ClipboardHotkey != "" {
+		label += " (" + c.ClipboardHotkey + ")"
+	}
+	var props = []*ibus.Property{
+		ibus.NewProperty(PropKeyClipboardConvert, ibus.PROP_TYPE_NORMAL, "Chuyển ngay", "", label, true, true, ibus.PROP_STATE_UNCHECKED),
+	}
+	for _, action := range clipboardActions {
+		var state = ibus.PROP_STATE_UNCHECKED
+		if action == c.ClipboardAction {
+			state = ibus.PROP_STATE_CHECKED
+		}
+		props = append(props, ibus.NewProperty(PropKeyClipboardAction+"::"+action, ibus.PROP_TYPE_RADIO, clipboardActionLabels[action], "", clipboardActionLabels[action], true, true, state))
+	}
+	var fromLabel = c.ClipboardFromCharset
+	if fromLabel == "" {
+		fromLabel = "Tự nhận dạng"
+	}
+	var fromProps = []*ibus.Property{
+		ibus.NewProperty(PropKeyClipboardFrom+"::", ibus.PROP_TYPE_RADIO, "Tự nhận dạng", "", "Tự nhận dạng", true, true, getRadioState(c.ClipboardFromCharset == "")),
+	}
+	var toProps []*ibus.Property
+	for _, charset := range core.GetCharsetNames() {
+		fromProps = append(fromProps, ibus.NewProperty(PropKeyClipboardFrom+"::"+charset, ibus.PROP_TYPE_RADIO, charset, "", charset, true, true, getRadioState(charset == c.ClipboardFromCharset)))
+		toProps = append(toProps, ibus.NewProperty(PropKeyClipboardTo+"::"+charset, ibus.PROP_TYPE_RADIO, charset, "", charset, true, true, getRadioState(charset == c.ClipboardToCharset)))
+	}
+	var isCharsetAction = c.ClipboardAction == ClipboardConvertCharset
+	props = append(props,
+		ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, "Từ bảng mã: "+fromLabel, "", "Bảng mã của clipboard", isCharsetAction, true,
+			ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(fromProps...)),
+		ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, "Sang bảng mã: "+c.ClipboardToCharset, "", "Bảng mã kết quả", isCharsetAction, true,
+			ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(toProps...)),
+	)
+	return ibus.NewPropertyWithChild("-", ibus.PROP_TYPE_MENU, "Clipboard: "+clipboardActionLabels[c.ClipboardAction], "", label, true, true,
+		ibus.PROP_STATE_UNCHECKED, *ibus.NewPropList(props...))
+}
+
+func getRadioState(checked bool) uint32 {
+	if checked {
+		return ibus.PROP_STATE_CHECKED
+	}
+	return ibus.PROP_STATE_UNCHECKED
 }
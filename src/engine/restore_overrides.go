@@ -0,0 +1,206 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andodevel/ibus-telex/src/core"
+)
+
+const restoreOverridesFile = "restore-overrides.json"
+
+// The languages a word can be forced into, as saved in the overrides file.
+const (
+	OverrideVietnamese = "vi"
+	OverrideEnglish    = "en"
+)
+
+var overrideLabels = map[string]string{
+	OverrideVietnamese: "tiếng Việt",
+	OverrideEnglish:    "tiếng Anh",
+}
+
+// restoreOverrides maps the keys of a word, in lower case, to the language the
+// user forced it into. Auto-restore follows them before the spelling rules.
+type restoreOverrides map[string]string
+
+// lookup returns whether the word typed with keys must be restored to English,
+// if the user forced it either way.
+func (o restoreOverrides) lookup(keys string) (bool, bool) {
+	var lang, found = o[strings.ToLower(keys)]
+	if !found || overrideLabels[lang] == "" {
+		return false, false
+	}
+	return lang == OverrideEnglish, true
+}
+
+func (o restoreOverrides) sortedKeys() []string {
+	var keys []string
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func getRestoreOverridesPath(ngName string) string {
+	return filepath.Join(getConfigDir(ngName), restoreOverridesFile)
+}
+
+// loadRestoreOverrides reads the overrides file, a missing file meaning that
+// nothing was learned yet.
+func loadRestoreOverrides(ngName string) (restoreOverrides, error) {
+	var o = restoreOverrides{}
+	var path = getRestoreOverridesPath(ngName)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return o, err
+	}
+	if err = json.Unmarshal(data, &o); err != nil {
+		return restoreOverrides{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return o, nil
+}
+
+func saveRestoreOverrides(ngName string, o restoreOverrides) error {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	setupConfigDir(ngName)
+	return ioutil.WriteFile(getRestoreOverridesPath(ngName), data, 0644)
+}
+
+// printRestoreOverrides lists the overrides of the user, after forgetting the
+// given keys, and returns the exit status.
+func printRestoreOverrides(w io.Writer, forgotten []string) int {
+	var engineName = strings.ToLower(EngineName)
+	var o, err = loadRestoreOverrides(engineName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(forgotten) > 0 {
+		for _, keys := range forgotten {
+			delete(o, strings.ToLower(keys))
+		}
+		if err = saveRestoreOverrides(engineName, o); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	for _, keys := range o.sortedKeys() {
+		fmt.Fprintf(w, "%s\t%s\n", keys, o[keys])
+	}
+	return 0
+}
+
+// lastWord follows the word auto-restore decided on at the last word break.
+// Deleting all of it and typing the same keys again means that the user wants
+// the other choice.
+type lastWord struct {
+	keys    string // in lower case, empty when no word is followed
+	english bool   // whether the word was restored to English
+	nLeft   int    // characters to delete before the word and its word break are gone
+}
+
+func (w lastWord) isRetyped(keys string) bool {
+	return w.keys != "" && w.nLeft <= 0 && w.keys == strings.ToLower(keys)
+}
+
+func (e *IBusTelex) loadRestoreOverrides() {
+	var err error
+	e.restoreOverrides, err = loadRestoreOverrides(e.engineName)
+	if err != nil {
+		log.Println(err)
+		showNotification("Auto-restore overrides", err.Error())
+	}
+}
+
+func (e *IBusTelex) saveRestoreOverrides() {
+	if err := saveRestoreOverrides(e.engineName, e.restoreOverrides); err != nil {
+		log.Println(err)
+	}
+}
+
+// getRestoreOverride looks the last word up in the overrides.
+func (e *IBusTelex) getRestoreOverride() (bool, bool) {
+	return e.restoreOverrides.lookup(e.getProcessedString(core.EnglishMode))
+}
+
+// trackLastWord counts the backspaces deleting the last word. Any other key
+// stops following the word, unless it is already deleted and being typed again.
+func (e *IBusTelex) trackLastWord(keyVal uint32) {
+	if e.lastWord.keys == "" || e.lastWord.nLeft <= 0 || e.nFakeBackSpace > 0 {
+		return
+	}
+	if keyVal == IBusBackSpace {
+		e.lastWord.nLeft--
+		return
+	}
+	e.lastWord = lastWord{}
+}
+
+// learnRetypedWord reverses the choice made on the last word when the user
+// typed it again, and remembers it. It reports whether it did so.
+func (e *IBusTelex) learnRetypedWord() bool {
+	var keys = e.getProcessedString(core.EnglishMode)
+	if !e.lastWord.isRetyped(keys) {
+		return false
+	}
+	var lang = OverrideEnglish
+	if e.lastWord.english {
+		lang = OverrideVietnamese
+	}
+	log.Printf("Auto-restore: %s is now %s", keys, lang)
+	e.restoreOverrides[strings.ToLower(keys)] = lang
+	e.lastWord = lastWord{}
+	e.saveRestoreOverrides()
+	e.propList = GetPropListByConfig(e.config, e.inputMethodFiles, e.wmClasses, e.restoreOverrides)
+	e.RegisterProperties(e.propList)
+	return true
+}
+
+// followLastWord follows the word that was just committed as text, provided
+// auto-restore had a choice to make on it.
+func (e *IBusTelex) followLastWord(text string) {
+	e.lastWord = lastWord{}
+	if e.config.IBflags&IBautoNonVnRestore == 0 || !core.HasAnyVietnameseRune(e.getProcessedString(core.VietnameseMode)) {
+		return
+	}
+	var keys = e.getProcessedString(core.EnglishMode)
+	e.lastWord = lastWord{
+		keys:    strings.ToLower(keys),
+		english: text == keys,
+		nLeft:   len(e.splitEncodedText(text)) + 1,
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andodevel/ibus-telex/src/core"
+	"github.com/andodevel/ibus-telex/src/x11"
+)
+
+// Snippet files are the .txt files of the snippets directory of the config
+// dir. They have the format of macro files, a trigger word being expanded at
+// a word break into its template, which may have these placeholders:
+//
+//	{date}      16/10/2026
+//	{longdate}  thứ Sáu, ngày 16 tháng 10 năm 2026
+//	{weekday}   thứ Sáu
+//	{day}, {month}, {year}, {hour}, {minute}
+//	{time}      14:05
+//	{clipboard} the text of the clipboard
+//	{cursor}    where the cursor is left, the word break being dropped
+//
+// \n is a new line, and \{ and \\ are a literal brace and backslash.
+const snippetFileExt = ".txt"
+
+var vnWeekdays = [...]string{"Chủ nhật", "thứ Hai", "thứ Ba", "thứ Tư", "thứ Năm", "thứ Sáu", "thứ Bảy"}
+
+const exampleSnippets = `# Mỗi dòng có dạng "từ khoá:mẫu", ví dụ:
+# ngayhomnay:{longdate}
+# ky:Trân trọng,\nNguyễn Văn A
+`
+
+func getSnippetsDir(engineName string) string {
+	return filepath.Join(getConfigDir(engineName), SnippetsDir)
+}
+
+// renderSnippet returns the text of a template and the byte offset of its
+// cursor placeholder, -1 if there is none.
+func renderSnippet(template string, now time.Time, getClipboard func() string) (string, int) {
+	var b strings.Builder
+	var cursor = -1
+	var runes = []rune(template)
+	for i := 0; i < len(runes); i++ {
+		var chr = runes[i]
+		if chr == '\\' && i+1 < len(runes) {
+			i++
+			if runes[i] == 'n' {
+				b.WriteRune('\n')
+			} else {
+				b.WriteRune(runes[i])
+			}
+			continue
+		}
+		var end = i + 1
+		for end < len(runes) && runes[end] != '}' {
+			end++
+		}
+		if chr != '{' || end == len(runes) {
+			b.WriteRune(chr)
+			continue
+		}
+		var name = string(runes[i+1 : end])
+		if name == "cursor" {
+			cursor = b.Len()
+		} else if value, found := getSnippetValue(name, now, getClipboard); found {
+			b.WriteString(value)
+		} else {
+			// unknown placeholders stay as they are
+			b.WriteString("{" + name + "}")
+		}
+		i = end
+	}
+	return b.String(), cursor
+}
+
+func getSnippetValue(name string, now time.Time, getClipboard func() string) (string, bool) {
+	switch name {
+	case "date":
+		return now.Format("02/01/2006"), true
+	case "longdate":
+		return fmt.Sprintf("%s, ngày %d tháng %d năm %d", vnWeekdays[now.Weekday()], now.Day(), now.Month(), now.Year()), true
+	case "weekday":
+		return vnWeekdays[now.Weekday()], true
+	case "day":
+		return fmt.Sprint(now.Day()), true
+	case "month":
+		return fmt.Sprint(int(now.Month())), true
+	case "year":
+		return fmt.Sprint(now.Year()), true
+	case "hour":
+		return now.Format("15"), true
+	case "minute":
+		return now.Format("04"), true
+	case "time":
+		return now.Format("15:04"), true
+	case "clipboard":
+		return getClipboard(), true
+	}
+	return "", false
+}
+
+// loadSnippets reads the snippet files again when one of them changed since
+// they were last read, later files overriding the triggers of earlier ones.
+func (e *IBusTelex) loadSnippets() {
+	var paths, _ = filepath.Glob(filepath.Join(getSnippetsDir(e.engineName), "*"+snippetFileExt))
+	sort.Strings(paths)
+	var modTime int64
+	for _, path := range append(paths, getSnippetsDir(e.engineName)) {
+		if info, err := os.Stat(path); err == nil && info.ModTime().UnixNano() > modTime {
+			modTime = info.ModTime().UnixNano()
+		}
+	}
+	if e.snippetTable != nil && modTime == e.snippetModTime {
+		return
+	}
+	var t = NewMacroTable()
+	var errs []string
+	for _, path := range paths {
+		var f, err = os.Open(path)
+		if err == nil {
+			err = t.Read(f)
+			f.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+	if len(errs) > 0 {
+		log.Println(strings.Join(errs, "\n"))
+		showNotification("Snippet file errors", strings.Join(errs, "\n"))
+	}
+	e.snippetTable = t
+	e.snippetModTime = modTime
+	log.Printf("Loaded %d snippets", t.Len())
+}
+
+// createSnippetsDir writes an example snippet file if the user has no snippet
+// directory, so that there is something to open.
+func createSnippetsDir(engineName string) {
+	var dir = getSnippetsDir(engineName)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return
+	}
+	setupConfigDir(engineName)
+	if err := os.Mkdir(dir, 0777); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "snippets"+snippetFileExt), []byte(exampleSnippets), 0644); err != nil {
+		log.Println(err)
+	}
+}
+
+// expandSnippet returns the text of the snippet triggered by the word that
+// ends with keyRune, looked up as shown and then as typed, and the text to
+// move the cursor back over. The word break is dropped when the template
+// places the cursor.
+func (e *IBusTelex) expandSnippet(text string, keyRune rune) (string, string, bool) {
+	for _, word := range []string{text, e.getProcessedString(core.EnglishMode)} {
+		if template, found := e.snippetTable.Lookup(word, false); found {
+			var expanded, cursor = renderSnippet(template, time.Now(), func() string {
+				return x11.GetClipboard(clipboardTimeoutMs)
+			})
+			if cursor < 0 {
+				return expanded + string(keyRune), "", true
+			}
+			return expanded, expanded[cursor:], true
+		}
+	}
+	return "", "", false
+}
+
+// moveCursorLeft moves the cursor back before text, in the input modes where
+// keys can be forwarded to the application.
+func (e *IBusTelex) moveCursorLeft(text string) {
+	if text == "" {
+		return
+	}
+	if e.checkInputMode(xTestFakeKeyEventIM) || e.checkInputMode(surroundingTextIM) {
+		log.Println("The cursor can not be moved in this input mode")
+		return
+	}
+	var n = len(e.splitEncodedText(text))
+	for i := 0; i < n; i++ {
+		e.ForwardKeyEvent(IBusLeft, XkLeft-8, 0)
+		e.ForwardKeyEvent(IBusLeft, XkLeft-8, IBusReleaseMask)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderSnippet(t *testing.T) {
+	// a Friday
+	var now = time.Date(2026, time.October, 16, 14, 5, 0, 0, time.Local)
+	var getClipboard = func() string { return "bản sao" }
+	var tests = []struct {
+		template string
+		expected string
+		cursor   int
+	}{
+		{`Trân trọng,\nNguyễn Văn A`, "Trân trọng,\nNguyễn Văn A", -1},
+		{`\{date} là {date}`, "{date} là 16/10/2026", -1},
+		{`C:\\Users`, `C:\Users`, -1},
+		{`cuối\`, `cuối\`, -1},
+		{"{longdate}", "thứ Sáu, ngày 16 tháng 10 năm 2026", -1},
+		{"{weekday} {day}/{month}/{year} {hour}:{minute}", "thứ Sáu 16/10/2026 14:05", -1},
+		{"{time}", "14:05", -1},
+		{"Dán: {clipboard}", "Dán: bản sao", -1},
+		{"{tên} ơi", "{tên} ơi", -1},
+		{"{date", "{date", -1},
+		{"a { b", "a { b", -1},
+		{"Kính gửi {cursor},", "Kính gửi ,", len("Kính gửi ")},
+		{"“{cursor}”", "“”", len("“")},
+		{"{cursor}đầu", "đầu", 0},
+	}
+	for _, test := range tests {
+		var text, cursor = renderSnippet(test.template, now, getClipboard)
+		if text != test.expected || cursor != test.cursor {
+			t.Errorf("Render %q. Got %q %d, expected %q %d", test.template, text, cursor, test.expected, test.cursor)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxSymbolCandidates = 90
+
+// A symbolTable finds characters for the symbol picker, by code point, e.g.
+// "u+20ab" or "20ab", or by the words of their Unicode names, e.g. "dong".
+type symbolTable struct {
+	names      map[rune]string
+	codePoints []rune // in file order
+}
+
+// loadSymbolTable reads a name table, which has one "code point;name" line
+// per character, lines starting with # being comments.
+func loadSymbolTable(path string) (*symbolTable, error) {
+	var t = &symbolTable{names: map[rune]string{}}
+	var f, err = os.Open(path)
+	if err != nil {
+		return t, err
+	}
+	defer f.Close()
+	var scanner = bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var fields = strings.SplitN(line, ";", 2)
+		var code, err = strconv.ParseUint(fields[0], 16, 32)
+		if err != nil || len(fields) < 2 || !utf8.ValidRune(rune(code)) {
+			return t, fmt.Errorf("%s:%d: expected code point;name, got %q", path, lineNumber, line)
+		}
+		t.names[rune(code)] = strings.ToUpper(fields[1])
+		t.codePoints = append(t.codePoints, rune(code))
+	}
+	return t, scanner.Err()
+}
+
+// parseCodePointQuery reads "u+" followed by 1 to 6 hex digits, or 4 to 6 hex
+// digits alone.
+func parseCodePointQuery(query string) (rune, bool) {
+	var hex = strings.TrimPrefix(strings.ToLower(query), "u+")
+	if len(hex) < 1 || len(hex) > 6 || hex == query && len(hex) < 4 {
+		return 0, false
+	}
+	var code, err = strconv.ParseUint(hex, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) || !unicode.IsGraphic(rune(code)) {
+		return 0, false
+	}
+	return rune(code), true
+}
+
+func (t *symbolTable) newCandidate(chr rune) candidate {
+	var shown = string(chr)
+	if unicode.Is(unicode.Mn, chr) {
+		// a combining mark is shown on a dotted circle
+		shown = "◌" + shown
+	}
+	var label = fmt.Sprintf("%s U+%04X", shown, chr)
+	if name, found := t.names[chr]; found {
+		label += " " + name
+	}
+	return candidate{text: string(chr), label: label}
+}
+
+// findCandidates returns the code point of the query, if it is one, then the
+// characters whose names have a word starting with each word of the query,
+// the shortest names first.
+func (t *symbolTable) findCandidates(query string) []candidate {
+	var candidates []candidate
+	var codePoint, isCodePoint = parseCodePointQuery(query)
+	if isCodePoint {
+		candidates = append(candidates, t.newCandidate(codePoint))
+	}
+	var queryWords = strings.Fields(strings.ToUpper(query))
+	if len(queryWords) == 0 {
+		return candidates
+	}
+	type match struct {
+		chr   rune
+		words int
+	}
+	var matches []match
+	for _, chr := range t.codePoints {
+		var nameWords = strings.Fields(t.names[chr])
+		if chr != codePoint && hasWordPrefixes(nameWords, queryWords) {
+			matches = append(matches, match{chr, len(nameWords)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].words < matches[j].words
+	})
+	for _, m := range matches {
+		if len(candidates) == maxSymbolCandidates {
+			break
+		}
+		candidates = append(candidates, t.newCandidate(m.chr))
+	}
+	return candidates
+}
+
+func hasWordPrefixes(words, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		var found = false
+		for _, word := range words {
+			if strings.HasPrefix(word, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *symbolTable) isQueryKey(key rune) bool {
+	return key >= 'a' && key <= 'z' || key >= 'A' && key <= 'Z' || key >= '0' && key <= '9' ||
+		key == ' ' || key == '+' || key == '-'
+}
+
+// selectsWithDigits is false as digits make up code points.
+func (t *symbolTable) selectsWithDigits() bool {
+	return false
+}
+
+func (e *IBusTelex) isSymbolPickerHotkey(keyVal, state uint32) bool {
+	return isHotkey(e.config.SymbolPickerHotkey, keyVal, state)
+}
+
+// openSymbolPicker opens the symbol picker, loading the name table the first
+// time. Without the table only code points can be picked.
+func (e *IBusTelex) openSymbolPicker() {
+	if e.symbolTable == nil {
+		var err error
+		e.symbolTable, err = loadSymbolTable(getEngineSubFile(DictUnicodeNames))
+		if err != nil {
+			log.Println(err)
+			showNotification("Unicode name table errors", err.Error())
+		}
+		log.Printf("Loaded %d symbol names", len(e.symbolTable.names))
+	}
+	e.openCandidateWindow(e.symbolTable, "Unicode: ", "")
+}
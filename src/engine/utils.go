@@ -36,13 +36,22 @@ import (
 const (
 	HomePage = "https://github.com/andodevel/ibus-telex"
 
-	DataDir      = "/usr/share/ibus-telex"
-	DictEmojiOne = "data/emojione.json"
+	DataDir          = "/usr/share/ibus-telex"
+	DictEmoji        = "data/emoji.json" // common emojis in the EmojiOne format
+	DictVietnamese   = "data/vietnamese.txt"
+	DictEnglish      = "data/english.txt"
+	DictUnicodeNames = "data/unicode-names.txt"
+	InputMethodsDir  = "input-methods"
+	SnippetsDir      = "snippets"
+
+	DefaultInputMethod = "Telex"
 )
 
 const (
-	configDir  = "%s/.config/ibus-%s"
-	configFile = "%s/ibus-%s.config.json"
+	configDir   = "%s/.config/ibus-%s"
+	configFile  = "%s/ibus-%s.config.json"
+	lexiconFile = "vietnamese.txt"
+	macroFile   = "%s/ibus-%s.macro.text"
 )
 
 const (
@@ -64,6 +73,19 @@ const (
 	IBstdFlags = IBautoNonVnRestore | IBddFreeStyle | IBmouseCapturing
 )
 
+// The spell checking modes of the menu, saved as core flags.
+const (
+	SpellCheckPhonotactic = "phonotactic"
+	SpellCheckDictionary  = "dictionary"
+	SpellCheckOff         = "off"
+)
+
+var spellCheckModeLabels = map[string]string{
+	SpellCheckPhonotactic: "Theo luật ghép vần",
+	SpellCheckDictionary:  "Theo từ điển",
+	SpellCheckOff:         "Tắt",
+}
+
 const (
 	JemojiEnabled uint = 1 << iota
 	JmacroEnabled
@@ -99,6 +121,7 @@ type Config struct {
 	InputMethod               string
 	InputMethodDefinitions    map[string]core.InputMethodDefinition
 	OutputCharset             string
+	OutputCharsetMapping      map[string]string
 	Flags                     uint
 	IBflags                   uint
 	JupiterFlags              uint
@@ -111,6 +134,15 @@ type Config struct {
 	SLForwardKeyWhiteList     []string
 	DirectForwardKeyWhiteList []string
 	SurroundingTextWhiteList  []string
+	ClipboardHotkey           string
+	ClipboardAction           string
+	ClipboardFromCharset      string // empty to detect it
+	ClipboardToCharset        string
+	SymbolPickerHotkey        string
+	AutocompleteWhiteList     []string // applications showing completions
+	LearningExceptedList      []string // applications whose words are not learned
+	EnglishRestoreList        []string // words restored on top of DictEnglish
+	EnglishRestoreExceptions  []string // words of DictEnglish to keep as Vietnamese
 }
 
 func getConfigDir(ngName string) string {
@@ -131,13 +163,63 @@ func getConfigPath(engineName string) string {
 	return fmt.Sprintf(configFile, getConfigDir(engineName), engineName)
 }
 
-func loadConfig(engineName string) *Config {
-	var c = Config{
-		InputMethod:               "Telex",
+// getInputMethodDirs returns where definition files are looked up, the user's
+// directory last so its files override the system ones.
+func getInputMethodDirs(ngName string) []string {
+	return []string{
+		filepath.Join(DataDir, InputMethodsDir),
+		filepath.Join(getConfigDir(ngName), InputMethodsDir),
+	}
+}
+
+func loadInputMethodFiles(ngName string) (map[string]*core.InputMethodFile, error) {
+	var files, err = core.LoadInputMethodDirs(getInputMethodDirs(ngName)...)
+	var imFiles = map[string]*core.InputMethodFile{}
+	for _, f := range files {
+		imFiles[f.Name] = f
+	}
+	return imFiles, err
+}
+
+// getLexiconPaths returns the lexicon files, the user's one adding syllables to
+// the system one.
+func getLexiconPaths(ngName string) []string {
+	return []string{
+		getEngineSubFile(DictVietnamese),
+		filepath.Join(getConfigDir(ngName), lexiconFile),
+	}
+}
+
+func getSpellCheckMode(flags uint) string {
+	if flags&core.EspellCheckDisabled != 0 {
+		return SpellCheckOff
+	}
+	if flags&core.EspellCheckWithDicts != 0 {
+		return SpellCheckDictionary
+	}
+	return SpellCheckPhonotactic
+}
+
+func setSpellCheckMode(flags uint, mode string) uint {
+	flags &= ^(core.EspellCheckDisabled | core.EspellCheckWithDicts)
+	switch mode {
+	case SpellCheckOff:
+		flags |= core.EspellCheckDisabled
+	case SpellCheckDictionary:
+		flags |= core.EspellCheckWithDicts
+	}
+	return flags
+}
+
+func newDefaultConfig() Config {
+	return Config{
+		InputMethod:               DefaultInputMethod,
 		OutputCharset:             "Unicode",
+		OutputCharsetMapping:      map[string]string{},
 		InputMethodDefinitions:    core.GetInputMethodDefinitions(),
 		Flags:                     core.EstdFlags,
 		IBflags:                   IBstdFlags,
+		JupiterFlags:              JstdFlags,
 		DefaultInputMode:          preeditIM,
 		InputModeMapping:          map[string]int{},
 		ExceptedList:              nil,
@@ -147,7 +229,20 @@ func loadConfig(engineName string) *Config {
 		SLForwardKeyWhiteList:     nil,
 		DirectForwardKeyWhiteList: nil,
 		SurroundingTextWhiteList:  nil,
+		ClipboardHotkey:           "Control+Shift+F9",
+		ClipboardAction:           ClipboardConvertCharset,
+		ClipboardFromCharset:      "",
+		ClipboardToCharset:        core.UNICODE,
+		SymbolPickerHotkey:        "Control+Shift+F8",
+		AutocompleteWhiteList:     nil,
+		LearningExceptedList:      nil,
+		EnglishRestoreList:        nil,
+		EnglishRestoreExceptions:  nil,
 	}
+}
+
+func loadConfig(engineName string) *Config {
+	var c = newDefaultConfig()
 
 	setupConfigDir(engineName)
 	data, err := ioutil.ReadFile(getConfigPath(engineName))
@@ -158,6 +253,20 @@ func loadConfig(engineName string) *Config {
 	return &c
 }
 
+// loadConfigFile reads a config file on top of the defaults, unlike loadConfig
+// it fails on unreadable or malformed files.
+func loadConfigFile(path string) (*Config, error) {
+	var c = newDefaultConfig()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &c, nil
+}
+
 func saveConfig(c *Config, engineName string) {
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
@@ -219,11 +328,22 @@ func addToWhiteList(list []string, classes string) []string {
 }
 
 func getCharsetFromPropKey(str string) (string, bool) {
-	var arr = strings.Split(str, "::")
-	if len(arr) == 2 {
-		return arr[1], true
+	return getValueFromPropKey(PropKeyCharset, str)
+}
+
+// getAppCharsetFromPropKey parses the key of an item of the per application
+// charset menu, an empty charset meaning the default one.
+func getAppCharsetFromPropKey(str string) (string, bool) {
+	return getValueFromPropKey(PropKeyAppCharset, str)
+}
+
+// getValueFromPropKey parses the key of a menu item made of the menu's key and
+// the item's value, e.g. "clipboard_to::VISCII".
+func getValueFromPropKey(menuKey, str string) (string, bool) {
+	if strings.HasPrefix(str, menuKey+"::") {
+		return strings.TrimPrefix(str, menuKey+"::"), true
 	}
-	return str, false
+	return "", false
 }
 
 func isValidCharset(str string) bool {
@@ -236,6 +356,16 @@ func isValidCharset(str string) bool {
 	return false
 }
 
+// summarizeErrors keeps the first maxLines lines of an error message, which is
+// enough for a desktop notification; the full message goes to the log.
+func summarizeErrors(err error, maxLines int) string {
+	var lines = strings.Split(err.Error(), "\n")
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], fmt.Sprintf("... and %d more", len(lines)-maxLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
 type byString []string
 
 func (s byString) Less(i, j int) bool {
@@ -0,0 +1,262 @@
+/*
+ * Telex - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Command telexconv converts Vietnamese text between the charsets of the
+// engine, e.g. from TCVN3 or VNI Windows files to Unicode:
+//
+//	telexconv -from tcvn3 -to unicode old.txt > new.txt
+//
+// or guesses the charset of files with -detect.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/andodevel/ibus-telex/src/core"
+)
+
+// NoAccents is an output only charset that strips tones and marks.
+const NoAccents = "No accents"
+
+var charsetAliases = map[string]string{
+	"utf8":        core.UNICODE,
+	"nfc":         core.UNICODE,
+	"nfd":         core.UnicodeNFD,
+	"tcvn3":       core.TCVN3,
+	"abc":         core.TCVN3,
+	"vni":         core.VNIWindows,
+	"windows1258": core.CP1258,
+	"ncr":         core.NCRDecimal,
+	"url":         core.URLEncoding,
+	"ascii":       NoAccents,
+	"khongdau":    NoAccents,
+}
+
+var from = flag.String("from", core.UNICODE, "Charset of the input")
+var to = flag.String("to", core.UNICODE, "Charset of the output")
+var list = flag.Bool("list", false, "List the charsets and exit")
+var detect = flag.Bool("detect", false, "Print the likely charsets of the input instead of converting it")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-from charset] [-to charset] [file...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -detect [file...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *list {
+		for _, name := range append(core.GetCharsetNames(), NoAccents) {
+			fmt.Println(name)
+		}
+		return
+	}
+	if *detect {
+		os.Exit(detectCharsets(os.Stdout, flag.Args()))
+	}
+	var c converter
+	var err error
+	if c.from, err = findCharsetName(*from); err == nil && c.from == NoAccents {
+		err = fmt.Errorf("%s can only be an output charset", NoAccents)
+	}
+	if err == nil {
+		c.to, err = findCharsetName(*to)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var w = bufio.NewWriter(os.Stdout)
+	c.w = w
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	var status = 0
+	for _, path := range paths {
+		var nLossy int
+		if path == "-" {
+			nLossy, err = c.convert("<stdin>", os.Stdin)
+		} else {
+			nLossy, err = c.convertFile(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+		if nLossy > 0 {
+			status = 1
+		}
+	}
+	if err = w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		status = 1
+	}
+	os.Exit(status)
+}
+
+// detectCharsets prints the charsets each input may be encoded in, the most
+// likely first, and returns the exit status.
+func detectCharsets(w io.Writer, paths []string) int {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	var status = 0
+	for _, path := range paths {
+		var data []byte
+		var err error
+		var name = path
+		if path == "-" {
+			name = "<stdin>"
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		var guesses = core.DetectCharset(data)
+		if guesses[0].Confidence == 0 {
+			fmt.Fprintf(w, "%s: no Vietnamese letters\n", name)
+			continue
+		}
+		for _, guess := range guesses {
+			if guess.Confidence > 0 {
+				fmt.Fprintf(w, "%s: %s (%.0f%%)\n", name, guess.Charset, guess.Confidence*100)
+			}
+		}
+	}
+	return status
+}
+
+// findCharsetName matches name against the charset names and aliases, ignoring
+// case, spaces and punctuation, so "vni-windows" means "VNI Windows".
+func findCharsetName(name string) (string, error) {
+	var key = normalizeCharsetName(name)
+	if charset, found := charsetAliases[key]; found {
+		return charset, nil
+	}
+	for _, charset := range append(core.GetCharsetNames(), NoAccents) {
+		if normalizeCharsetName(charset) == key {
+			return charset, nil
+		}
+	}
+	return "", fmt.Errorf("unknown charset %q, see -list", name)
+}
+
+func normalizeCharsetName(name string) string {
+	return strings.Map(func(chr rune) rune {
+		if unicode.IsLetter(chr) || unicode.IsDigit(chr) {
+			return unicode.ToLower(chr)
+		}
+		return -1
+	}, name)
+}
+
+type converter struct {
+	from string
+	to   string
+	w    io.Writer
+}
+
+func (c *converter) convertFile(path string) (int, error) {
+	var f, err = os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return c.convert(path, f)
+}
+
+// convert writes the converted lines of r and returns how many of them could
+// not be converted without loss, which are reported on stderr.
+func (c *converter) convert(name string, r io.Reader) (int, error) {
+	var reader = bufio.NewReader(r)
+	var nLossy = 0
+	for lineNumber := 1; ; lineNumber++ {
+		var line, err = reader.ReadString('\n')
+		if line != "" {
+			var output, exact = c.encode(core.Decode(c.from, readText(c.from, line)))
+			if !exact {
+				fmt.Fprintf(os.Stderr, "%s:%d: can not be converted to %s exactly\n", name, lineNumber, c.to)
+				nLossy++
+			}
+			if _, werr := io.WriteString(c.w, output); werr != nil {
+				return nLossy, werr
+			}
+		}
+		if err == io.EOF {
+			return nLossy, nil
+		}
+		if err != nil {
+			return nLossy, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// encode returns the bytes to write for text and whether decoding them gives
+// text back.
+func (c *converter) encode(text string) (string, bool) {
+	if c.to == NoAccents {
+		return core.RemoveAccents(text), true
+	}
+	var encoded = core.Encode(c.to, text)
+	var output, exact = writeText(c.to, encoded)
+	return output, exact && core.Decode(c.to, encoded) == text
+}
+
+// readText turns the bytes of a legacy charset into one rune per byte, which
+// is what its decoder expects. Other charsets are read as UTF-8.
+func readText(charset, bytes string) string {
+	if !core.IsLegacyCharset(charset) {
+		return bytes
+	}
+	var runes = make([]rune, len(bytes))
+	for i := 0; i < len(bytes); i++ {
+		runes[i] = rune(bytes[i])
+	}
+	return string(runes)
+}
+
+// writeText is the reverse of readText. Runes that do not fit a byte are
+// written as '?' and make the result inexact.
+func writeText(charset, text string) (string, bool) {
+	if !core.IsLegacyCharset(charset) {
+		return text, true
+	}
+	var exact = true
+	var bytes = make([]byte, 0, len(text))
+	for _, chr := range text {
+		if chr > 0xFF {
+			chr = '?'
+			exact = false
+		}
+		bytes = append(bytes, byte(chr))
+	}
+	return string(bytes), exact
+}
@@ -25,6 +25,7 @@ package x11
 #include <stdlib.h>
 
 extern void x11Copy(char*);
+extern char* x11GetClipboard(int);
 extern void x11Paste(int);
 extern void clipboard_init();
 extern void clipboard_exit();
@@ -93,6 +94,17 @@ func Copy(str string) {
 	C.x11Copy(cs)
 }
 
+// GetClipboard returns the text of the clipboard, or "" if it is empty or its
+// owner does not answer within timeout milliseconds.
+func GetClipboard(timeout int) string {
+	var text = C.x11GetClipboard(C.int(timeout))
+	if text != nil {
+		defer C.free(unsafe.Pointer(text))
+		return C.GoString(text)
+	}
+	return ""
+}
+
 func ClipboardInit() {
 	C.clipboard_init()
 }
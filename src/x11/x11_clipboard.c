@@ -22,11 +22,13 @@
 #include <pthread.h>
 #include <stdlib.h>
 #include <stdio.h>
-#define MAX_TEXT_LEN 100
+#include <limits.h>
+#include <unistd.h>
 
 static pthread_t th_clipboard;
 static int clipboard_running;
 static char * text = NULL;
+static pthread_mutex_t text_mutex = PTHREAD_MUTEX_INITIALIZER;
 static char * old_text = NULL;
 static int done = 0;
 
@@ -72,7 +74,11 @@ static void* thread_clipboard_copy(void* data) {
                     XSendEvent (display, ev.requestor, 0, 0, (XEvent *)&ev);
                     break;
                 }
-                if (text == NULL) break;
+                pthread_mutex_lock(&text_mutex);
+                if (text == NULL) {
+                    pthread_mutex_unlock(&text_mutex);
+                    break;
+                }
                 int size = strlen(text);
                 if (ev.target == targets_atom) {
                     R = XChangeProperty (ev.display, ev.requestor, ev.property, XA_ATOM, 32, PropModeReplace, (unsigned char*)&UTF8, 1);
@@ -86,6 +92,7 @@ static void* thread_clipboard_copy(void* data) {
                     done = 1;
                 }
                 else ev.property = None;
+                pthread_mutex_unlock(&text_mutex);
                 if ((R & 2) == 0) XSendEvent (display, ev.requestor, 0, 0, (XEvent *)&ev);
                 break;
             case SelectionClear:
@@ -112,21 +119,68 @@ void clipboard_init()
     }
 }
 
+// set_text replaces the text served to other clients, whatever its length.
+static void set_text(const char *str) {
+    pthread_mutex_lock(&text_mutex);
+    free(text);
+    text = strdup(str);
+    pthread_mutex_unlock(&text_mutex);
+}
+
 void x11ClipboardReset() {
-    if (text == NULL) {
-        text = (char*)calloc(MAX_TEXT_LEN, sizeof(char));
-    }
-    strcpy(text, "");
+    set_text("");
 }
 
 void x11Copy(char *str) {
-    if (text == NULL) {
-        text = (char*)calloc(MAX_TEXT_LEN, sizeof(char));
-    }
-    strcpy(text, str);
+    set_text(str);
     done = 0;
-    fprintf(stderr, "...x11Clipboard text=%s, clipboard_running=%d\n", text, clipboard_running);
+    fprintf(stderr, "...x11Clipboard text=%s, clipboard_running=%d\n", str, clipboard_running);
     if (clipboard_running == 0) {
         clipboard_init();
     }
 }
+
+// x11GetClipboard returns a copy of the text of the CLIPBOARD selection, to be
+// freed by the caller, or NULL if the owner does not answer within timeout
+// milliseconds. Incremental (INCR) transfers of large texts are not supported.
+char* x11GetClipboard(int timeout) {
+    Display* display = XOpenDisplay(0);
+    if (!display) {
+        return NULL;
+    }
+    int N = DefaultScreen(display);
+    Window window = XCreateSimpleWindow(display, RootWindow(display, N), 0, 0, 1, 1, 0,
+        BlackPixel(display, N), WhitePixel(display, N));
+    Atom selection = XInternAtom(display, "CLIPBOARD", 0);
+    Atom utf8 = XInternAtom(display, "UTF8_STRING", 0);
+    Atom incr = XInternAtom(display, "INCR", 0);
+    Atom property = XInternAtom(display, "IBUS_TELEX_CLIPBOARD", 0);
+    char *result = NULL;
+
+    XConvertSelection(display, selection, utf8, property, window, CurrentTime);
+    XFlush(display);
+    XEvent event;
+    for (int waited = 0; waited < timeout; waited += 10) {
+        if (!XCheckTypedWindowEvent(display, window, SelectionNotify, &event)) {
+            usleep(10000);
+            continue;
+        }
+        if (event.xselection.property != None) {
+            Atom type;
+            int format;
+            unsigned long n, remaining;
+            unsigned char *data = NULL;
+            if (XGetWindowProperty(display, window, property, 0, LONG_MAX / 4, True, AnyPropertyType,
+                    &type, &format, &n, &remaining, &data) == Success && data != NULL) {
+                if (type != incr && format == 8) {
+                    result = strndup((char*)data, n);
+                }
+                XFree(data);
+            }
+        }
+        break;
+    }
+    XDestroyWindow(display, window);
+    XCloseDisplay(display);
+    return result;
+}